@@ -2,11 +2,15 @@ package ast
 
 import (
 	"bytes"
+
+	"monkey/token"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of first character belonging to the node
+	End() token.Position // position of first character immediately after the node
 }
 
 type Statement interface {
@@ -21,6 +25,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*CommentGroup // all comments in source order
 }
 
 func (p *Program) TokenLiteral() string {
@@ -30,6 +35,20 @@ func (p *Program) TokenLiteral() string {
 	return p.Statements[0].TokenLiteral()
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
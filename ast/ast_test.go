@@ -29,3 +29,137 @@ func TestString(t *testing.T) {
 	require.Equal(t, "let myVar = anotherVar;", program.String())
 
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"hello", `"hello"`},
+		{"a\nb\tc\r", `"a\nb\tc\r"`},
+		{`say "hi" \o/`, `"say \"hi\" \\o/"`},
+		{"grüße 你好", `"grüße 你好"`},
+		{"\x00\x7f\u200b", `"\x00\x7f\u{200b}"`},
+	}
+
+	for _, tt := range tests {
+		sl := &ast.StringLiteral{Token: token.New(token.STRING, tt.value), Value: tt.value}
+		require.Equal(t, tt.expected, sl.String())
+	}
+}
+
+func TestRawStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{`C:\dir\n`, `"""C:\dir\n"""`},
+		{"a\n  b\n", "\"\"\"a\n  b\n\"\"\""},
+		{"\nx", "\"\"\"\nx\"\"\""},
+		{"\n  ", `"\n  "`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a """ b`, `"a \"\"\" b"`},
+	}
+
+	for _, tt := range tests {
+		sl := &ast.StringLiteral{Token: token.New(token.RAW_STRING, tt.value), Value: tt.value, Raw: true}
+		require.Equal(t, tt.expected, sl.String())
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression { return &ast.IntegerLiteral{Token: token.New(token.INT, "1"), Value: 1} }
+	two := func() ast.Expression { return &ast.IntegerLiteral{Token: token.New(token.INT, "2"), Value: 2} }
+	block := func(exp ast.Expression) *ast.BlockStatement {
+		return &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: exp}}}
+	}
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    ast.Node
+		expected ast.Node
+	}{
+		{one(), two()},
+		{
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: one()}}},
+			&ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&ast.InfixExpression{Left: one(), Operator: "+", Right: one()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.PrefixExpression{Operator: "-", Right: one()},
+			&ast.PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&ast.IndexExpression{Left: one(), Index: one()},
+			&ast.IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&ast.IfExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())},
+			&ast.IfExpression{Condition: two(), Consequence: block(two()), Alternative: block(two())},
+		},
+		{
+			&ast.ReturnStatement{ReturnValue: one()},
+			&ast.ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: one()},
+			&ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&ast.ExportStatement{Statement: &ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: one()}},
+			&ast.ExportStatement{Statement: &ast.LetStatement{Name: &ast.Identifier{Value: "x"}, Value: two()}},
+		},
+		{
+			&ast.LetStatement{
+				Pattern: &ast.ArrayPattern{Elements: []ast.Pattern{&ast.DefaultPattern{Pattern: &ast.Identifier{Value: "x"}, Default: one()}}},
+				Value:   one(),
+			},
+			&ast.LetStatement{
+				Pattern: &ast.ArrayPattern{Elements: []ast.Pattern{&ast.DefaultPattern{Pattern: &ast.Identifier{Value: "x"}, Default: two()}}},
+				Value:   two(),
+			},
+		},
+		{
+			&ast.FunctionLiteral{Parameters: []*ast.Identifier{}, Body: block(one())},
+			&ast.FunctionLiteral{Parameters: []*ast.Identifier{}, Body: block(two())},
+		},
+		{
+			&ast.WhileStatement{Condition: one(), Body: block(one())},
+			&ast.WhileStatement{Condition: two(), Body: block(two())},
+		},
+		{
+			&ast.CallExpression{Function: &ast.Identifier{Value: "f"}, Arguments: []ast.Expression{one(), &ast.SpreadExpression{Value: one()}}},
+			&ast.CallExpression{Function: &ast.Identifier{Value: "f"}, Arguments: []ast.Expression{two(), &ast.SpreadExpression{Value: two()}}},
+		},
+		{
+			&ast.ArrayLiteral{Elements: []ast.Expression{one(), one()}},
+			&ast.ArrayLiteral{Elements: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.TemplateLiteral{Texts: []string{"a", "b"}, Holes: []ast.Expression{one()}},
+			&ast.TemplateLiteral{Texts: []string{"a", "b"}, Holes: []ast.Expression{two()}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := ast.Modify(tt.input, turnOneIntoTwo)
+		require.Equal(t, tt.expected, modified)
+	}
+
+	hash := &ast.HashLiteral{Pairs: map[ast.Expression]ast.Expression{one(): one(), one(): one()}}
+	ast.Modify(hash, turnOneIntoTwo)
+	for key, val := range hash.Pairs {
+		require.Equal(t, two(), key)
+		require.Equal(t, two(), val)
+	}
+}
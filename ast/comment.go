@@ -0,0 +1,63 @@
+package ast
+
+import (
+	"strings"
+
+	"monkey/token"
+)
+
+// A Comment represents a single //-style or /*-style comment.
+type Comment struct {
+	Token token.Token // the COMMENT token
+	Text  string      // comment text, including the comment markers
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) String() string       { return c.Text }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos }
+func (c *Comment) End() token.Position  { return c.Token.End }
+
+// A CommentGroup represents a sequence of comments with no other tokens
+// and no empty lines between.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) TokenLiteral() string { return g.List[0].TokenLiteral() }
+func (g *CommentGroup) Pos() token.Position  { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Position  { return g.List[len(g.List)-1].End() }
+func (g *CommentGroup) String() string {
+	comments := []string{}
+	for _, c := range g.List {
+		comments = append(comments, c.String())
+	}
+	return strings.Join(comments, "\n")
+}
+
+// Text returns the text of the comment group with the comment markers and
+// the space following them, the leading and trailing blank lines and the
+// trailing white space of every line removed.
+func (g *CommentGroup) Text() string {
+	lines := []string{}
+	for _, c := range g.List {
+		text := c.Text
+		if strings.HasPrefix(text, "//") {
+			text = text[2:]
+		} else {
+			text = strings.TrimSuffix(text[2:], "*/")
+		}
+		text = strings.TrimPrefix(text, " ")
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
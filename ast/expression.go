@@ -7,14 +7,78 @@ import (
 	"monkey/token"
 )
 
+// A BadExpression is a placeholder for an expression containing syntax
+// errors for which a correct expression node cannot be created.
+type BadExpression struct {
+	From, To token.Position // position range of the bad expression
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return "" }
+func (be *BadExpression) Pos() token.Position  { return be.From }
+func (be *BadExpression) End() token.Position  { return be.To }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
+// An AssignExpression represents an assignment to a variable or to an
+// element of an array or hash, either plain or compound like x += 1.
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // *Identifier or *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position  { return ae.Value.End() }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// A SpreadExpression represents ...xs in call arguments and array
+// literals, it stands for the elements of the array xs.
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadExpression) End() token.Position  { return se.Value.End() }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// A KeywordArgument represents a name: value call argument.
+type KeywordArgument struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (ka *KeywordArgument) expressionNode()      {}
+func (ka *KeywordArgument) TokenLiteral() string { return ka.Name.TokenLiteral() }
+func (ka *KeywordArgument) Pos() token.Position  { return ka.Name.Pos() }
+func (ka *KeywordArgument) End() token.Position  { return ka.Value.End() }
+func (ka *KeywordArgument) String() string       { return ka.Name.String() + ": " + ka.Value.String() }
+
 type PrefixExpression struct {
-	Token    *token.Token
+	Token    token.Token
 	Operator string
 	Right    Expression
 }
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -27,7 +91,7 @@ func (pe *PrefixExpression) String() string {
 }
 
 type InfixExpression struct {
-	Token    *token.Token
+	Token    token.Token
 	Left     Expression
 	Operator string
 	Right    Expression
@@ -35,6 +99,8 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position  { return ie.Right.End() }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -48,14 +114,21 @@ func (ie *InfixExpression) String() string {
 }
 
 type IfExpression struct {
-	Token       *token.Token
+	Token       token.Token
 	Condition   Expression
 	Consequence *BlockStatement
-	Alternative *BlockStatement
+	Alternative Node // *BlockStatement, *IfExpression for else if, or nil
 }
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -65,21 +138,73 @@ func (ie *IfExpression) String() string {
 	out.WriteString(ie.Consequence.String())
 
 	if ie.Alternative != nil {
-		out.WriteString(" ")
+		out.WriteString(" else ")
 		out.WriteString(ie.Alternative.String())
 	}
 
 	return out.String()
 }
 
+// A MatchExpression evaluates the body of the first arm whose pattern
+// matches Value and whose guard, if any, holds.
+type MatchExpression struct {
+	Token  token.Token // the 'match' token
+	Value  Expression
+	Arms   []*MatchArm
+	Rbrace token.Token // the '}' token
+}
+
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // nil without an if guard
+	Body    Expression
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) End() token.Position  { return me.Rbrace.End }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Value.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	s := ma.Pattern.String()
+	if ma.Guard != nil {
+		s += " if " + ma.Guard.String()
+	}
+	return s + " => " + ma.Body.String()
+}
+
 type CallExpression struct {
-	Token     *token.Token
+	Token     token.Token // the '(' token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token // the ')' token
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.Is(token.RPAREN) {
+		return ce.Rparen.End
+	}
+	return ce.Token.End
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -97,13 +222,21 @@ func (ce *CallExpression) String() string {
 }
 
 type IndexExpression struct {
-	Token *token.Token
-	Left  Expression
-	Index Expression
+	Token  token.Token // the '[' token
+	Left   Expression
+	Index  Expression
+	Rbrack token.Token // the ']' token
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position {
+	if ie.Rbrack.Is(token.RBRACKET) {
+		return ie.Rbrack.End
+	}
+	return ie.Index.End()
+}
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -2,79 +2,260 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"monkey/token"
 	"strings"
+	"unicode"
 )
 
 type Identifier struct {
-	Token *token.Token
+	Token token.Token
 	Value string
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 func (i *Identifier) String() string       { return i.Value }
 
 type IntegerLiteral struct {
-	Token *token.Token
+	Token token.Token
 	Value int64
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type Boolean struct {
-	Token *token.Token
+	Token token.Token
 	Value bool
 }
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type StringLiteral struct {
-	Token *token.Token
+	Token token.Token
 	Value string
+	Raw   bool // written as a """raw string"""
 }
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) String() string {
+	if sl.Raw && rawQuotable(sl.Value) {
+		return `"""` + sl.Value + `"""`
+	}
+	return quote(sl.Value)
+}
+
+// rawQuotable reports whether s reads back unchanged as a raw string, it
+// must not end the literal early nor be trimmed as a multi-line one.
+func rawQuotable(s string) bool {
+	if strings.Contains(s, `"""`) || strings.HasSuffix(s, `"`) {
+		return false
+	}
+	if strings.HasPrefix(s, "\n") {
+		last := s[strings.LastIndexByte(s, '\n')+1:]
+		return strings.Trim(last, " \t") != ""
+	}
+	return true
+}
+
+// quote returns a double-quoted string literal representing s, escaping
+// the characters that cannot appear verbatim.
+func quote(s string) string {
+	var out bytes.Buffer
+
+	out.WriteByte('"')
+	for _, ch := range s {
+		switch ch {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			switch {
+			case unicode.IsPrint(ch):
+				out.WriteRune(ch)
+			case ch < 0x100:
+				fmt.Fprintf(&out, `\x%02x`, ch)
+			default:
+				fmt.Fprintf(&out, `\u{%x}`, ch)
+			}
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
+
+// A TemplateLiteral represents a `text ${hole} text` string, Texts has
+// one more element than Holes.
+type TemplateLiteral struct {
+	Token token.Token // the TEMPLATE or TEMPLATE_HEAD token
+	Texts []string
+	Holes []Expression
+	Tail  token.Token // the TEMPLATE_TAIL token, if any
+}
+
+func (tl *TemplateLiteral) expressionNode()      {}
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) Pos() token.Position  { return tl.Token.Pos }
+func (tl *TemplateLiteral) End() token.Position {
+	if tl.Tail.Is(token.TEMPLATE_TAIL) {
+		return tl.Tail.End
+	}
+	return tl.Token.End
+}
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteByte('`')
+	for i, text := range tl.Texts {
+		if i > 0 {
+			out.WriteString("${")
+			out.WriteString(tl.Holes[i-1].String())
+			out.WriteString("}")
+		}
+		out.WriteString(quoteTemplate(text))
+	}
+	out.WriteByte('`')
+
+	return out.String()
+}
+
+// quoteTemplate escapes the characters of s that would end the template
+// text or start a hole.
+func quoteTemplate(s string) string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '`' || ch == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(ch)
+		case ch == '$' && i+1 < len(s) && s[i+1] == '{':
+			out.WriteString(`\$`)
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	return out.String()
+}
 
 type FunctionLiteral struct {
-	Token      *token.Token
+	Token      token.Token
+	Name       string // declared or let-bound name, if any
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values of the trailing parameters
+	Rest       *Identifier           // the ...rest parameter, if any
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(fl.ParameterList())
+	out.WriteString(") ")
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
 
+// ParameterList returns the parameters as written between the parentheses.
+func (fl *FunctionLiteral) ParameterList() string {
+	params := []string{}
 	for _, p := range fl.Parameters {
+		if def, ok := fl.Defaults[p.Value]; ok {
+			params = append(params, p.String()+" = "+def.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
+	return strings.Join(params, ", ")
+}
+
+// A MacroLiteral defines a macro, a function of the unevaluated syntax of
+// its arguments that returns the quoted syntax replacing its call.
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
+func (ml *MacroLiteral) End() token.Position  { return ml.Body.End() }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
 		params = append(params, p.String())
 	}
 
-	out.WriteString(fl.TokenLiteral())
+	out.WriteString(ml.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(ml.Body.String())
 
 	return out.String()
 }
 
 type ArrayLiteral struct {
-	Token    *token.Token
+	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbrack   token.Token // the ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position {
+	if al.Rbrack.Is(token.RBRACKET) {
+		return al.Rbrack.End
+	}
+	return al.Token.End
+}
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -91,12 +272,20 @@ func (al *ArrayLiteral) String() string {
 }
 
 type HashLiteral struct {
-	Token *token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Token // the '}' token
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position {
+	if hl.Rbrace.Is(token.RBRACE) {
+		return hl.Rbrace.End
+	}
+	return hl.Token.End
+}
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
@@ -0,0 +1,125 @@
+package ast
+
+// A ModifierFunc returns the node that replaces node in the tree.
+type ModifierFunc func(node Node) Node
+
+// Modify walks the tree rooted at node depth first, replacing every
+// statement and expression with the result of calling modifier on it
+// after its children have been modified. The names bound by let, for and
+// function declarations are left as they are.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+
+	// Statements
+	case *Program:
+		for i, stmt := range node.Statements {
+			node.Statements[i], _ = Modify(stmt, modifier).(Statement)
+		}
+	case *ExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+	case *BlockStatement:
+		for i, stmt := range node.Statements {
+			node.Statements[i], _ = Modify(stmt, modifier).(Statement)
+		}
+	case *LetStatement:
+		if node.Pattern != nil {
+			node.Pattern = modifyPattern(node.Pattern, modifier)
+		}
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ReturnStatement:
+		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+	case *FunctionStatement:
+		node.Function, _ = Modify(node.Function, modifier).(*FunctionLiteral)
+	case *ExportStatement:
+		node.Statement, _ = Modify(node.Statement, modifier).(Statement)
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForStatement:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	// Expressions
+	case *PrefixExpression:
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *InfixExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *AssignExpression:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *SpreadExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *KeywordArgument:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *IfExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative = Modify(node.Alternative, modifier)
+		}
+	case *MatchExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+		for _, arm := range node.Arms {
+			arm.Pattern = modifyPattern(arm.Pattern, modifier)
+			if arm.Guard != nil {
+				arm.Guard, _ = Modify(arm.Guard, modifier).(Expression)
+			}
+			arm.Body, _ = Modify(arm.Body, modifier).(Expression)
+		}
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, arg := range node.Arguments {
+			node.Arguments[i], _ = Modify(arg, modifier).(Expression)
+		}
+	case *IndexExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+
+	// Literals
+	case *TemplateLiteral:
+		for i, hole := range node.Holes {
+			node.Holes[i], _ = Modify(hole, modifier).(Expression)
+		}
+	case *FunctionLiteral:
+		for name, def := range node.Defaults {
+			node.Defaults[name], _ = Modify(def, modifier).(Expression)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *MacroLiteral:
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ArrayLiteral:
+		for i, el := range node.Elements {
+			node.Elements[i], _ = Modify(el, modifier).(Expression)
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression)
+		for key, val := range node.Pairs {
+			newKey, _ := Modify(key, modifier).(Expression)
+			newVal, _ := Modify(val, modifier).(Expression)
+			pairs[newKey] = newVal
+		}
+		node.Pairs = pairs
+	}
+
+	return modifier(node)
+}
+
+// modifyPattern modifies the default values in pattern, the only
+// expressions of a pattern that are evaluated.
+func modifyPattern(pattern Pattern, modifier ModifierFunc) Pattern {
+	switch pattern := pattern.(type) {
+	case *DefaultPattern:
+		pattern.Pattern = modifyPattern(pattern.Pattern, modifier)
+		pattern.Default, _ = Modify(pattern.Default, modifier).(Expression)
+	case *ArrayPattern:
+		for i, el := range pattern.Elements {
+			pattern.Elements[i] = modifyPattern(el, modifier)
+		}
+	case *HashPattern:
+		for _, pair := range pattern.Pairs {
+			pair.Value = modifyPattern(pair.Value, modifier)
+		}
+	}
+	return pattern
+}
@@ -0,0 +1,117 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"monkey/token"
+)
+
+// A Pattern describes the shape of a value and the names its parts are
+// bound to, an *Identifier binds the whole value.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+func (i *Identifier) patternNode() {}
+
+// A WildcardPattern, written _, matches any value without binding it.
+type WildcardPattern struct {
+	Token token.Token // the '_' token
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) Pos() token.Position  { return wp.Token.Pos }
+func (wp *WildcardPattern) End() token.Position  { return wp.Token.End }
+func (wp *WildcardPattern) String() string       { return "_" }
+
+// A LiteralPattern matches the values equal to a number, string or
+// boolean literal.
+type LiteralPattern struct {
+	Value Expression // a literal, or a number literal negated by a PrefixExpression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) Pos() token.Position  { return lp.Value.Pos() }
+func (lp *LiteralPattern) End() token.Position  { return lp.Value.End() }
+func (lp *LiteralPattern) String() string {
+	if pe, ok := lp.Value.(*PrefixExpression); ok {
+		return pe.Operator + pe.Right.String()
+	}
+	return lp.Value.String()
+}
+
+// An ArrayPattern matches an array element by element, the remaining
+// elements are bound to Rest as an array.
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []Pattern
+	Rest     *Identifier // the ...rest element, if any
+	Rbrack   token.Token // the ']' token
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) End() token.Position  { return ap.Rbrack.End }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// A HashPattern matches the values of the listed keys of a hash, {name}
+// is short for {name: name}.
+type HashPattern struct {
+	Token  token.Token // the '{' token
+	Pairs  []*HashPatternPair
+	Rbrace token.Token // the '}' token
+}
+
+type HashPatternPair struct {
+	Key   *StringLiteral
+	Value Pattern
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) End() token.Position  { return hp.Rbrace.End }
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// A DefaultPattern matches like Pattern, Default is used in place of a
+// missing array element or hash value.
+type DefaultPattern struct {
+	Pattern Pattern
+	Default Expression
+}
+
+func (dp *DefaultPattern) patternNode()         {}
+func (dp *DefaultPattern) TokenLiteral() string { return dp.Pattern.TokenLiteral() }
+func (dp *DefaultPattern) Pos() token.Position  { return dp.Pattern.Pos() }
+func (dp *DefaultPattern) End() token.Position  { return dp.Default.End() }
+func (dp *DefaultPattern) String() string {
+	return dp.Pattern.String() + " = " + dp.Default.String()
+}
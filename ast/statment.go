@@ -2,23 +2,55 @@ package ast
 
 import (
 	"bytes"
+	"path"
+	"strings"
 
 	"monkey/token"
 )
 
+// A BadStatement is a placeholder for a statement containing syntax errors
+// for which a correct statement node cannot be created.
+type BadStatement struct {
+	From, To token.Position // position range of the bad statement
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return "" }
+func (bs *BadStatement) Pos() token.Position  { return bs.From }
+func (bs *BadStatement) End() token.Position  { return bs.To }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
+// A LetStatement binds Value to Name, or to the names of Pattern when it
+// destructures the value. The bindings of a const statement are constant.
 type LetStatement struct {
-	Token *token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // the 'let' or 'const' token
+	Name    *Identifier
+	Pattern Pattern // an *ArrayPattern or a *HashPattern, nil with a Name
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.target().End()
+}
+
+// target returns the pattern the value is bound to.
+func (ls *LetStatement) target() Pattern {
+	if ls.Pattern != nil {
+		return ls.Pattern
+	}
+	return ls.Name
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.target().String())
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -29,13 +61,93 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// A FunctionStatement represents a fn name(params) { ... } declaration.
+type FunctionStatement struct {
+	Token    token.Token // the 'fn' token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode()       {}
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *FunctionStatement) End() token.Position  { return fs.Function.End() }
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fs.TokenLiteral() + " ")
+	out.WriteString(fs.Name.String())
+	out.WriteString("(")
+	out.WriteString(fs.Function.ParameterList())
+	out.WriteString(") ")
+	out.WriteString(fs.Function.Body.String())
+
+	return out.String()
+}
+
+// An ImportStatement binds the module imported from Path to Alias, or to
+// the base name of Path without its extension.
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	Alias *Identifier // the name after as, if any
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position {
+	if is.Alias != nil {
+		return is.Alias.End()
+	}
+	return is.Path.End()
+}
+func (is *ImportStatement) String() string {
+	s := "import " + is.Path.String()
+	if is.Alias != nil {
+		s += " as " + is.Alias.String()
+	}
+	return s + ";"
+}
+
+// Name returns the name the module is bound to.
+func (is *ImportStatement) Name() string {
+	if is.Alias != nil {
+		return is.Alias.Value
+	}
+	name := path.Base(is.Path.Value)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// An ExportStatement makes the names bound by a let statement or declared
+// by a function statement visible to the importers of the module.
+type ExportStatement struct {
+	Token     token.Token // the 'export' token
+	Statement Statement   // a *LetStatement or a *FunctionStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) End() token.Position  { return es.Statement.End() }
+func (es *ExportStatement) String() string {
+	return "export " + es.Statement.String()
+}
+
 type ReturnStatement struct {
-	Token       *token.Token
+	Token       token.Token
 	ReturnValue Expression
 }
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -51,12 +163,19 @@ func (rs *ReturnStatement) String() string {
 }
 
 type ExpressionStatement struct {
-	Token      *token.Token
+	Token      token.Token
 	Expression Expression
 }
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression == nil {
 		return ""
@@ -64,13 +183,90 @@ func (es *ExpressionStatement) String() string {
 	return es.Expression.String()
 }
 
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// A ForStatement represents a for (x in iterable) { ... } loop.
+type ForStatement struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return "continue;" }
+
 type BlockStatement struct {
-	Token      *token.Token
+	Token      token.Token // the '{' token
 	Statements []Statement
+	Rbrace     token.Token // the '}' token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.Is(token.RBRACE) {
+		return bs.Rbrace.End
+	}
+	if n := len(bs.Statements); n > 0 {
+		return bs.Statements[n-1].End()
+	}
+	return bs.Token.End
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
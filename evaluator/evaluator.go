@@ -2,16 +2,43 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
 )
 
 var NULL = &object.Null{}
 var TRUE = &object.Boolean{Value: true}
 var FALSE = &object.Boolean{Value: false}
+var BREAK = &object.Break{}
+var CONTINUE = &object.Continue{}
+
+// BlockScoping makes every block evaluate in a scope of its own, so that
+// the bindings made by let inside an if or loop body end with the body.
+// Clearing it restores the legacy behaviour of blocks binding in their
+// enclosing scope.
+var BlockScoping = true
+
+// StrictMatch makes a match expression without a matching arm an error.
+// Clearing it makes such a match evaluate to null instead.
+var StrictMatch = true
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+
+	// errors are positioned at the innermost node they surface from
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
@@ -20,23 +47,45 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatements(node.Statements, env)
+		return evalBlockStatements(node.Statements, blockScope(env))
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
-			return val
-		}
-		env.Set(node.Name.Value, val)
+		return evalLetStatement(node, env)
 	case *ast.ReturnStatement:
 		return evalReturnStatement(node, env)
+	case *ast.FunctionStatement:
+		// bound when the enclosing scope was entered, see hoistFunctions
+		return NULL
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError("syntax error")
+	case *ast.SpreadExpression:
+		// expanded by the calls and array literals they belong to
+		return newError("cannot spread outside of a call or an array literal")
+	case *ast.KeywordArgument:
+		return newError("keyword argument `%s` outside of a call", node.Name.Value)
 
 	// Expression
 	case *ast.PrefixExpression:
 		return evalPrefixExpression(node, env)
 	case *ast.InfixExpression:
 		return evalInfixExpression(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.IfExpression:
 		return evalIfExpressiion(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.CallExpression:
 		return evalCallExpression(node, env)
 	case *ast.IndexExpression:
@@ -47,16 +96,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIdentifier(node, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
 	case *ast.FunctionLiteral:
-		params, body := node.Parameters, node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return newFunction(node, env)
+	case *ast.MacroLiteral:
+		// bound before evaluation, see DefineMacros
+		return newError("macro literals must be bound by a top-level let")
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
@@ -70,6 +125,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	if err := hoistFunctions(program.Statements, env); err != nil {
+		return err
+	}
+
 	for _, stmt := range program.Statements {
 		result = Eval(stmt, env)
 
@@ -88,9 +147,63 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
+// hoistFunctions binds the functions declared by stmts in env before any
+// of them runs, so that they can be called before their declaration and
+// call each other.
+func hoistFunctions(stmts []ast.Statement, env *object.Environment) object.Object {
+	for _, stmt := range stmts {
+		if es, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = es.Statement
+		}
+		if fs, ok := stmt.(*ast.FunctionStatement); ok {
+			if !env.Declare(fs.Name.Value, newFunction(fs.Function, env), false) {
+				err := redeclarationError(fs.Name.Value)
+				err.Pos = fs.Pos()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// evalLetStatement binds the value of a let or const statement. The names
+// of a pattern are bound once it has matched as a whole, from a scope of
+// its own in which the defaults see the names before them.
+func evalLetStatement(ls *ast.LetStatement, env *object.Environment) object.Object {
+	val := Eval(ls.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	constant := ls.Token.Is(token.CONST)
+
+	if ls.Pattern == nil {
+		if !env.Declare(ls.Name.Value, val, constant) {
+			return redeclarationError(ls.Name.Value)
+		}
+		return nil
+	}
+
+	scope := object.NewClosedEnvironment(env)
+	if err := bindPattern(ls.Pattern, val, scope); err != nil {
+		return err
+	}
+	for _, name := range patternNames(ls.Pattern) {
+		bound, _ := scope.Get(name)
+		if !env.Declare(name, bound, constant) {
+			return redeclarationError(name)
+		}
+	}
+	return nil
+}
+
+func redeclarationError(name string) *object.Error {
+	return newError("cannot redeclare constant %s", name)
+}
+
 func evalReturnStatement(rs *ast.ReturnStatement, env *object.Environment) object.Object {
 	val := Eval(rs.ReturnValue, env)
-	if isError(val) {
+	if isAbrupt(val) {
 		return val
 	}
 	return &object.ReturnValue{Value: val}
@@ -99,12 +212,16 @@ func evalReturnStatement(rs *ast.ReturnStatement, env *object.Environment) objec
 func evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
+	if err := hoistFunctions(stmts, env); err != nil {
+		return err
+	}
+
 	for _, stmt := range stmts {
 		result = Eval(stmt, env)
 
 		if result != nil {
-			ttype := result.Type()
-			if ttype == object.RETURN_VALUE_OBJ || ttype == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -117,7 +234,7 @@ func evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.
 
 func evalPrefixExpression(pe *ast.PrefixExpression, env *object.Environment) object.Object {
 	right := Eval(pe.Right, env)
-	if isError(right) {
+	if isAbrupt(right) {
 		return right
 	}
 
@@ -126,6 +243,8 @@ func evalPrefixExpression(pe *ast.PrefixExpression, env *object.Environment) obj
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitNotPrefixOperatorExpression(right)
 	}
 	return newError("unknown operator: %s%s", pe.Operator, right.Type())
 }
@@ -143,38 +262,172 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	}
+	return newError("unknown operator: -%s", right.Type())
+}
+
+func evalBitNotPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError("unknown operator: ~%s", right.Type())
 	}
 
 	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return &object.Integer{Value: ^value}
 }
 
 func evalInfixExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	if ie.Operator == "&&" || ie.Operator == "||" {
+		return evalLogicalExpression(ie, env)
+	}
+
 	left := Eval(ie.Left, env)
-	if isError(left) {
+	if isAbrupt(left) {
 		return left
 	}
 	right := Eval(ie.Right, env)
-	if isError(right) {
+	if isAbrupt(right) {
 		return right
 	}
 
+	return evalInfixOperator(ie.Operator, left, right)
+}
+
+func evalInfixOperator(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(ie.Operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(ie.Operator, left, right)
-	case ie.Operator == "==":
+		return evalStringInfixExpression(operator, left, right)
+	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
-	case ie.Operator == "!=":
+	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), ie.Operator, right.Type())
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		return evalIdentifierAssignment(ae, target, env)
+	case *ast.IndexExpression:
+		return evalIndexAssignment(ae, target, env)
+	}
+	return newError("cannot assign to %s", ae.Target)
+}
+
+func evalIdentifierAssignment(ae *ast.AssignExpression, target *ast.Identifier, env *object.Environment) object.Object {
+	if env.IsConst(target.Value) {
+		return newError("cannot assign to constant %s", target.Value)
+	}
+
+	val := Eval(ae.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if ae.Operator != "=" {
+		current, ok := env.Get(target.Value)
+		if !ok {
+			return newError("assignment to undeclared identifier: %s", target.Value)
+		}
+		val = evalInfixOperator(strings.TrimSuffix(ae.Operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
 	}
 
-	return newError("unknown operator: %s %s %s", left.Type(), ie.Operator, right.Type())
+	if !env.Assign(target.Value, val) {
+		return newError("assignment to undeclared identifier: %s", target.Value)
+	}
+	return val
+}
+
+func evalIndexAssignment(ae *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if isAbrupt(index) {
+		return index
+	}
+	val := Eval(ae.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if ae.Operator != "=" {
+		current := evalIndexOperator(left, index)
+		if isError(current) {
+			return current
+		}
+		val = evalInfixOperator(strings.TrimSuffix(ae.Operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		if left.Frozen {
+			return newError("cannot modify frozen array %s", target.Left)
+		}
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("index operator not supported: %s", left.Type())
+		}
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d", idx.Value)
+		}
+		left.Elements[idx.Value] = val
+	case *object.Hash:
+		if left.Frozen {
+			return newError("cannot modify frozen hash %s", target.Left)
+		}
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+
+	return val
+}
+
+// evalLogicalExpression evaluates && and ||, the right operand is only
+// evaluated when the left one does not decide the result.
+func evalLogicalExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(ie.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if ie.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if ie.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(ie.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -189,13 +442,78 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		return &object.Integer{Value: integerPower(leftVal, rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << uint64(rightVal)}
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// evalFloatInfixExpression evaluates operators on two floats or on a
+// float and an integer, the integer is converted to float.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -218,7 +536,7 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 func evalIfExpressiion(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 
-	if isError(condition) {
+	if isAbrupt(condition) {
 		return condition
 	}
 
@@ -233,35 +551,181 @@ func evalIfExpressiion(ie *ast.IfExpression, env *object.Environment) object.Obj
 	return NULL
 }
 
+// evalMatchExpression evaluates the body of the first arm that matches,
+// each arm binds the names of its pattern in a scope of its own.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	val := Eval(me.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	for _, arm := range me.Arms {
+		scope := object.NewClosedEnvironment(env)
+
+		result := matchPattern(arm.Pattern, val, scope)
+		if _, ok := result.(*mismatch); ok {
+			continue
+		}
+		if isAbrupt(result) {
+			return result
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, scope)
+			if isAbrupt(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, scope)
+	}
+
+	if !StrictMatch {
+		return NULL
+	}
+	return newError("no match for %s", val.Inspect())
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		if result, done := evalLoopBody(ws.Body, blockScope(env)); done {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	var elements []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements = iterable.Elements
+	case *object.String:
+		for _, ch := range iterable.Value {
+			elements = append(elements, &object.String{Value: string(ch)})
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, el := range elements {
+		// each iteration has its own binding of the variable for the
+		// closures created in the body to capture
+		scope := blockScope(env)
+		if !scope.Declare(fs.Variable.Value, el, false) {
+			return redeclarationError(fs.Variable.Value)
+		}
+
+		if result, done := evalLoopBody(fs.Body, scope); done {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// evalLoopBody evaluates one iteration of a loop in the scope env, done
+// reports whether the loop ends with result.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	switch result := evalBlockStatements(body.Statements, env).(type) {
+	case *object.Break:
+		return NULL, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	}
+	return nil, false
+}
+
+// blockScope returns the environment to evaluate a block in, nested in env.
+func blockScope(env *object.Environment) *object.Environment {
+	if !BlockScoping {
+		return env
+	}
+	return object.NewClosedEnvironment(env)
+}
+
 func evalCallExpression(ce *ast.CallExpression, env *object.Environment) object.Object {
+	if ident, ok := ce.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+		if len(ce.Arguments) != 1 {
+			return newError("wrong number of arguments to `quote`. got=%d, want=1", len(ce.Arguments))
+		}
+		return quote(ce.Arguments[0], env)
+	}
+
 	function := Eval(ce.Function, env)
-	if isError(function) {
+	if isAbrupt(function) {
 		return function
 	}
 
-	args := evalExpressions(ce.Arguments, env)
-	if len(args) == 1 && isError(args[0]) {
+	// the parser puts the keyword arguments last
+	positional := ce.Arguments
+	for len(positional) > 0 {
+		if _, ok := positional[len(positional)-1].(*ast.KeywordArgument); !ok {
+			break
+		}
+		positional = positional[:len(positional)-1]
+	}
+
+	args := evalExpressions(positional, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
 		return args[0]
 	}
 
-	return applyFunction(function, args)
+	var kwargs map[string]object.Object
+	for _, arg := range ce.Arguments[len(positional):] {
+		kwarg := arg.(*ast.KeywordArgument)
+		if _, ok := kwargs[kwarg.Name.Value]; ok {
+			return newError("duplicate keyword argument `%s`", kwarg.Name.Value)
+		}
+		val := Eval(kwarg.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		if kwargs == nil {
+			kwargs = make(map[string]object.Object)
+		}
+		kwargs[kwarg.Name.Value] = val
+	}
+
+	return applyFunction(function, args, kwargs)
 }
 
 func evalIndexExpression(ie *ast.IndexExpression, env *object.Environment) object.Object {
 	left := Eval(ie.Left, env)
-	if isError(left) {
+	if isAbrupt(left) {
 		return left
 	}
 	index := Eval(ie.Index, env)
-	if isError(index) {
+	if isAbrupt(index) {
 		return index
 	}
 
+	return evalIndexOperator(left, index)
+}
+
+func evalIndexOperator(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, index)
 	}
 	return newError("index operator not supported: %s", left.Type())
 }
@@ -295,8 +759,23 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	var result []object.Object
 
 	for _, exp := range exps {
+		if spread, ok := exp.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isAbrupt(evaluated) {
+				return []object.Object{evaluated}
+			}
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				err := newError("cannot spread %s", evaluated.Type())
+				err.Pos = spread.Pos()
+				return []object.Object{err}
+			}
+			result = append(result, array.Elements...)
+			continue
+		}
+
 		evaluated := Eval(exp, env)
-		if isError(evaluated) {
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -307,12 +786,28 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 
 // eval literal
 
+func evalTemplateLiteral(node *ast.TemplateLiteral, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	out.WriteString(node.Texts[0])
+	for i, hole := range node.Holes {
+		val := Eval(hole, env)
+		if isAbrupt(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+		out.WriteString(node.Texts[i+1])
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if isAbrupt(key) {
 			return key
 		}
 
@@ -322,7 +817,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		}
 
 		value := Eval(valueNode, env)
-		if isError(value) {
+		if isAbrupt(value) {
 			return value
 		}
 
@@ -346,27 +841,124 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 }
 
 // help
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(fn object.Object, args []object.Object, kwargs map[string]object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		// the body shares the scope of the parameters
+		extendedEnv, err := extendFunctionEnv(fn, args, kwargs)
+		if err != nil {
+			return err
+		}
+		evaluated := evalBlockStatements(fn.Body.Statements, extendedEnv)
+		if err, ok := evaluated.(*object.Error); ok && err.Function == "" {
+			err.Function = fn.Name
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
+		if len(kwargs) > 0 {
+			return newError("builtin functions do not accept keyword arguments")
+		}
 		return fn.Fn(args...)
 	}
 
 	return newError("not a function: %s", fn.Type())
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the parameters of fn in a new scope, from the
+// positional arguments, then the keyword arguments, then the default
+// values, which are evaluated in the scope of the parameters before them.
+func extendFunctionEnv(fn *object.Function, args []object.Object, kwargs map[string]object.Object) (*object.Environment, object.Object) {
 	env := object.NewClosedEnvironment(fn.Env)
 
+	if len(args) > len(fn.Parameters) && fn.Rest == nil {
+		return nil, arityError(fn, len(args)+len(kwargs))
+	}
+
+	names := make([]string, 0, len(kwargs))
+	for name := range kwargs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isParameter(fn, name) {
+			return nil, newError("unexpected keyword argument `%s`%s", name, callee(fn))
+		}
+	}
+
 	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
+		kwarg, isKeyword := kwargs[param.Value]
+		def, hasDefault := fn.Defaults[param.Value]
+		switch {
+		case i < len(args) && isKeyword:
+			return nil, newError("multiple values for argument `%s`%s", param.Value, callee(fn))
+		case i < len(args):
+			env.Set(param.Value, args[i])
+		case isKeyword:
+			env.Set(param.Value, kwarg)
+		case hasDefault:
+			val := Eval(def, env)
+			if isAbrupt(val) {
+				return nil, val
+			}
+			env.Set(param.Value, val)
+		default:
+			return nil, arityError(fn, len(args)+len(kwargs))
+		}
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
+}
+
+func isParameter(fn *object.Function, name string) bool {
+	for _, param := range fn.Parameters {
+		if param.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+func arityError(fn *object.Function, got int) *object.Error {
+	required := len(fn.Parameters) - len(fn.Defaults)
+
+	var want string
+	switch {
+	case fn.Rest != nil:
+		want = fmt.Sprintf("at least %d", required)
+	case required == len(fn.Parameters):
+		want = fmt.Sprintf("%d", required)
+	default:
+		want = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	}
+
+	return newError("wrong number of arguments%s. got=%d, want=%s", callee(fn), got, want)
+}
+
+// callee names fn in the errors about its arguments.
+func callee(fn *object.Function) string {
+	if fn.Name == "" {
+		return ""
+	}
+	return " to `" + fn.Name + "`"
+}
+
+func newFunction(fl *ast.FunctionLiteral, env *object.Environment) *object.Function {
+	return &object.Function{
+		Name:       fl.Name,
+		Parameters: fl.Parameters,
+		Defaults:   fl.Defaults,
+		Rest:       fl.Rest,
+		Body:       fl.Body,
+		Env:        env,
+	}
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -397,6 +989,40 @@ func isTruthy(obj object.Object) bool {
 	return true
 }
 
+// integerPower returns base**exp for exp >= 0 by repeated squaring.
+func integerPower(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
 func isError(obj object.Object) bool {
 	return obj != nil && obj.Type() == object.ERROR_OBJ
 }
+
+// isAbrupt reports whether obj is an error, a break or a continue. Like an
+// error, a break or continue met within an expression, in the body of an
+// if for instance, ends the evaluation of the expressions around it.
+func isAbrupt(obj object.Object) bool {
+	return isError(obj) || obj == BREAK || obj == CONTINUE
+}
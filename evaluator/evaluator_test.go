@@ -1,8 +1,12 @@
 package evaluator_test
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
@@ -35,6 +39,20 @@ func testNullObject(t *testing.T, obj object.Object, msg string) {
 	require.Equal(t, evaluator.NULL, obj, msg)
 }
 
+// testExpectedObject checks obj against expected, an int for an integer,
+// a string for the message of an error or nil for null.
+func testExpectedObject(t *testing.T, obj object.Object, expected interface{}, msg string) {
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, obj, int64(expected), msg)
+	case string:
+		require.IsType(t, new(object.Error), obj, msg)
+		require.Equal(t, expected, obj.(*object.Error).Message, msg)
+	default:
+		testNullObject(t, obj, msg)
+	}
+}
+
 // test
 func TestEvalIntegerExpression(t *testing.T) {
 	tests := []struct {
@@ -56,6 +74,22 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"0xFF", 255},
+		{"0o755", 493},
+		{"0b1010 + 1", 11},
+		{"1_000_000", 1000000},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"0b1100 & 0b1010", 8},
+		{"0b1100 | 0b1010", 14},
+		{"0b1100 ^ 0b1010", 6},
+		{"~0", -1},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"1 + 2 << 1", 6},
 	}
 
 	for _, tt := range tests {
@@ -64,6 +98,35 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64, msg string) {
+	require.IsType(t, new(object.Float), obj, msg)
+	require.InDelta(t, expected, obj.(*object.Float).Value, 1e-12, msg)
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1e-9", 1e-9},
+		{"0.1 + 0.2", 0.3},
+		{"1.5 * 2", 3},
+		{"7.5 % 2", 1.5},
+		{"2 ** 0.5", 1.4142135623730951},
+		{"2 ** -1", 0.5},
+		{"3 / 2.0", 1.5},
+		{"10 - 0.5", 9.5},
+		{"-(1 + 0.5)", -1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected, "TestCase: "+tt.input)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -88,6 +151,24 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 2.5", false},
+		{"1 == 1.0", true},
+		{"0.5 != 0.5", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 <= 1", false},
+		{"1.5 >= 1", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 && 0", true},
+		{"false && foobar", false},
+		{"true || foobar", true},
 	}
 
 	for _, tt := range tests {
@@ -139,6 +220,32 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (1 > 2) { 1 } else if (2 > 1) { 2 } else { 3 }", 2},
+		{"if (1 > 2) { 1 } else if (2 > 3) { 2 } else { 3 }", 3},
+		{"if (1 > 2) { 1 } else if (2 > 3) { 2 }", nil},
+		{"if (true) { 1 } else if (-true) { 2 }", 1},
+		{"if (false) { 1 } else if (-true) { 2 }", "unknown operator: -BOOLEAN"},
+		{
+			`let sign = fn(x) {
+				if (x < 0) { return -1; } else if (x == 0) { return 0; }
+				1
+			};
+			sign(-5) * 100 + sign(0) * 10 + sign(7)`,
+			-99,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -207,6 +314,30 @@ func TestErrorHandling(t *testing.T) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"true && foobar",
+			"identifier not found: foobar",
+		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"1 % 0",
+			"division by zero",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"~true",
+			"unknown operator: ~BOOLEAN",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -270,6 +401,133 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn add(a, b) { a + b } add(1, 2)", 3},
+		{"let x = twice(4); fn twice(n) { n * 2 }; x", 8},
+		{
+			`fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+			if (isEven(10) && isOdd(7)) { 1 } else { 0 }`,
+			1,
+		},
+		{
+			`fn outer() {
+				let r = inner();
+				fn inner() { 5 }
+				r
+			}
+			outer()`,
+			5,
+		},
+		{"if (true) { fn hidden() { 1 } }; hidden()", "identifier not found: hidden"},
+		{"fn f() { 1 } f = fn() { 2 }; f()", 2},
+		{"let f = fn() { fn g() { 1 } }; f()", nil},
+		{"let f = fn() { fn g() { 1 } }; f() + 1", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(a, b) { a + b } add", "fn add(a, b) {\n(a + b)\n}"},
+		{"let sub = fn(a, b) { a - b }; sub", "fn sub(a, b) {\n(a - b)\n}"},
+		{"fn(a) { a }", "fn(a) {\na\n}"},
+		{"fn check(x) {\n  x + true\n}\ncheck(1)", "ERROR: 2:3: type mismatch: INTEGER + BOOLEAN (in check)"},
+		{"fn outer() { fn(x) { -x }(true) } outer()", "ERROR: 1:22: unknown operator: -BOOLEAN (in outer)"},
+		{"fn outer() { inner() } fn inner() { -true } outer()", "ERROR: 1:37: unknown operator: -BOOLEAN (in inner)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.Equal(t, tt.expected, evaluated.Inspect(), "TestCase: "+tt.input)
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn add(x, y = 10) { x + y } add(1)", 11},
+		{"fn add(x, y = 10) { x + y } add(1, 2)", 3},
+		{"fn f(x, y = x * 2) { y } f(4)", 8},
+		{"let n = 0; fn f(x = n += 1) { x } f(); f(); f()", 3},
+		{"fn sum(...xs) { let s = 0; for (x in xs) { s += x; }; s } sum(1, 2, 3)", 6},
+		{"fn count(first, ...rest) { len(rest) } count(1)", 0},
+		{"fn count(first, ...rest) { len(rest) } count(1, 2, 3)", 2},
+		{"fn add(x, y) { x + y } let xs = [1, 2]; add(...xs)", 3},
+		{"fn add(x, y, z) { x + y + z } add(...[1], 2, ...[3])", 6},
+		{"let xs = [2, 3]; let ys = [1, ...xs, 4, ...[]]; len(ys) * 10 + ys[2]", 43},
+		{"fn f(a, b = 2, c = 3) { a * 100 + b * 10 + c } f(1, c: 9)", 129},
+		{"fn f(a, b = 2, c = 3) { a * 100 + b * 10 + c } f(c: 7, a: 5)", 527},
+		{"fn f(x) { x } f()", "wrong number of arguments to `f`. got=0, want=1"},
+		{"fn f(x) { x } f(1, 2)", "wrong number of arguments to `f`. got=2, want=1"},
+		{"fn(x, y = 1) { x }(1, 2, 3)", "wrong number of arguments. got=3, want=1 to 2"},
+		{"fn f(x, ...r) { x } f()", "wrong number of arguments to `f`. got=0, want=at least 1"},
+		{"fn f(x) { x } f(1, x: 2)", "multiple values for argument `x` to `f`"},
+		{"fn f(x) { x } f(y: 2)", "unexpected keyword argument `y` to `f`"},
+		{"fn f(x) { x } f(x: 1, x: 2)", "duplicate keyword argument `x`"},
+		{"fn f(x) { x } f(...5)", "cannot spread INTEGER"},
+		{"fn f(x = -true) { x } f()", "unknown operator: -BOOLEAN"},
+		{"len(x: [1])", "builtin functions do not accept keyword arguments"},
+		{"len(...[[1, 2]])", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+
+	evaluated := testEval("fn f(a, b = 1, ...c) { a } f")
+	require.Equal(t, "fn f(a, b = 1, ...c) {\na\n}", evaluated.Inspect())
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [first, ...rest] = [1, 2, 3]; first * 10 + len(rest)", 12},
+		{"let [x, ...rest] = [1]; len(rest)", 0},
+		{"let [a, b = a + 1] = [1]; b", 2},
+		{"let [a, b = 5] = [1, 2]; b", 2},
+		{`let {name, age: years} = {"name": "ann", "age": 30}; years + len(name)`, 33},
+		{`let {x = 7} = {}; x`, 7},
+		{`let {x = 7} = {"x": 1}; x`, 1},
+		{`let {pos: [x, y], "the tag": {id}} = {"pos": [1, 2], "the tag": {"id": 3}}; x + y + id`, 6},
+		{"let [[a, b], [c]] = [[1, 2], [3]]; a + b + c", 6},
+		{"let [a, b] = [1]", "not enough elements to destructure: got=1, want=2"},
+		{"let [a, b = 1, c = 2] = []", "not enough elements to destructure: got=0, want=1 to 3"},
+		{"let [a, b, ...c] = [1]", "not enough elements to destructure: got=1, want=at least 2"},
+		{"let [a] = [1, 2]", "too many elements to destructure: got=2, want=1"},
+		{"let [a] = 1", "cannot destructure INTEGER with an array pattern"},
+		{"let {a} = [1]", "cannot destructure ARRAY with a hash pattern"},
+		{`let {a} = {"b": 1}`, "key not found in hash: a"},
+		{`let {a: [b]} = {"a": {}}`, "cannot destructure HASH with an array pattern"},
+		{"let [a = -true] = []", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+
+	evaluated := testEval("let a = 1;\nlet {b: [c]} = {\"b\": 2};")
+	require.Equal(t, "ERROR: 2:9: cannot destructure INTEGER with an array pattern", evaluated.Inspect())
+}
+
 func TestClosures(t *testing.T) {
 	input := `
       let newAdder = fn(x) {
@@ -469,6 +727,14 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{0.0: 5}[-0.0]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -481,3 +747,553 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = 2", 2},
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x %= 4; x", 2},
+		{"let f = fn() { x = 7; }; let x = 1; f(); x", 7},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let inc = fn(x) { x = x + 1; x }; let x = 1; inc(x); x", 1},
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; a[2] += 10; a[2]", 13},
+		{"let a = [[1], [2]]; a[1][0] = 9; a[1][0]", 9},
+		{"let h = {\"a\": 1}; h[\"a\"] = 2; h[\"b\"] = 3; h[\"a\"] + h[\"b\"]", 5},
+		{"x = 1", "assignment to undeclared identifier: x"},
+		{"x += 1", "assignment to undeclared identifier: x"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"let a = [1]; a[\"x\"] = 2", "index operator not supported: ARRAY"},
+		{"let h = {}; h[fn(x) { x }] = 1", "unusable as hash key: FUNCTION"},
+		{"let s = \"ab\"; s[0] = \"c\"", "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+
+	evaluated := testEval(`let s = "a"; s += "b"; s`)
+	require.IsType(t, new(object.String), evaluated)
+	require.Equal(t, "ab", evaluated.Inspect())
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1; }; i", 10},
+		{"let i = 0; while (false) { i += 1; }; i", 0},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; }; s", 6},
+		{"let s = 0; for (x in []) { s += 1; }; s", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break; } }; i", 5},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue; } s += x; }; s", 4},
+		{
+			`let s = 0;
+			for (x in [1, 2, 3]) {
+				for (y in [10, 20, 30]) {
+					if (y == 20) { break; }
+					s += x * y;
+				}
+			};
+			s`,
+			60,
+		},
+		{"let f = fn(xs) { for (x in xs) { if (x > 2) { return x; } } return -1; }; f([1, 5, 3])", 5},
+		{"let f = fn(xs) { for (x in xs) { if (x > 9) { return x; } } return -1; }; f([1, 5, 3])", -1},
+		{"let g = fn(x) { x * 2 }; let s = 0; for (x in [1, 2]) { s += g(x); }; s", 6},
+		{"let x = 0; while (true) { let y = if (true) { break; }; x += 1; if (x > 3) { break; } }; x", 0},
+		{"let s = 0; for (x in [1, 2, 3]) { s += if (x == 2) { continue; } else { x }; }; s", 4},
+		{"let n = 0; for (x in [1, 2, 3]) { n += len([x, if (x == 2) { break; }]); }; n", 2},
+		{"while (false) { 1 }", nil},
+		{"let i = 0; while (true) { break; }", nil},
+		{"let f = fn() { for (x in [1]) { x } }; f()", nil},
+		{"let f = fn() { for (x in [1]) { x } }; f() + 1", "type mismatch: NULL + INTEGER"},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"while (-true) { 1 }", "unknown operator: -BOOLEAN"},
+		{"for (x in [1]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+
+	evaluated := testEval(`let s = ""; for (c in "héllo") { s = c + s; }; s`)
+	require.IsType(t, new(object.String), evaluated)
+	require.Equal(t, "olléh", evaluated.Inspect())
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { let x = 1; }; x", "identifier not found: x"},
+		{"let x = 1; if (true) { let x = 2; }; x", 1},
+		{"let x = 1; if (true) { let x = 2; x } else { 0 }", 2},
+		{"let x = 1; if (true) { x = 2; }; x", 2},
+		{"let x = 1; while (x < 3) { let y = x; x += 1; }; y", "identifier not found: y"},
+		{"for (x in [1]) { x }; x", "identifier not found: x"},
+		{
+			`let fs = [0, 0, 0];
+			let i = 0;
+			for (x in [1, 2, 3]) { fs[i] = fn() { x }; i += 1; };
+			fs[0]() * 100 + fs[1]() * 10 + fs[2]()`,
+			123,
+		},
+		{
+			`let f = if (true) { let secret = 42; fn() { secret } };
+			f()`,
+			42,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+}
+
+func TestLegacyBlockScoping(t *testing.T) {
+	evaluator.BlockScoping = false
+	defer func() { evaluator.BlockScoping = true }()
+
+	testIntegerObject(t, testEval("let x = 1; if (true) { let x = 2; }; x"), 2, "")
+	testIntegerObject(t, testEval("if (true) { let y = 3; }; y"), 3, "")
+	testExpectedObject(t, testEval("const x = 1; for (x in [2]) {}; x"), "cannot redeclare constant x", "")
+}
+
+func TestMatchExpression(t *testing.T) {
+	classify := `
+	let classify = fn(x) {
+		match (x) {
+			0 => "zero",
+			-1 => "minus one",
+			1.5 => "one and a half",
+			true => "yes",
+			"hi" => "greeting",
+			[] => "empty",
+			[a] => "one: " + a,
+			[a, b] if a == b => "pair",
+			[a, ...rest] => a + " and more",
+			{kind: "circle", r} => "circle " + r,
+			{kind, size = "small"} => kind + " " + size,
+			n if n > 100 => "big",
+			_ => "other",
+		}
+	};
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"classify(0)", "zero"},
+		{"classify(-1)", "minus one"},
+		{"classify(0.0)", "other"},
+		{"classify(1.5)", "one and a half"},
+		{"classify(true)", "yes"},
+		{`classify("hi")`, "greeting"},
+		{"classify([])", "empty"},
+		{`classify(["x"])`, "one: x"},
+		{"classify([2, 2])", "pair"},
+		{`classify(["y", 3, 4])`, "y and more"},
+		{`classify({"kind": "circle", "r": "wide"})`, "circle wide"},
+		{`classify({"kind": "box"})`, "box small"},
+		{"classify(101)", "big"},
+		{"classify(5)", "other"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(classify + tt.input)
+		require.IsType(t, new(object.String), evaluated, tt.input)
+		require.Equal(t, tt.expected, evaluated.(*object.String).Value, tt.input)
+	}
+
+	testIntegerObject(t, testEval("let a = 1; match (2) { a => a }; a"), 1, "arm bindings are scoped")
+	testIntegerObject(t, testEval("match ([1, 2]) { [a, 1] => 0, [b, 2] => b }"), 1, "")
+
+	evaluated := testEval("let x = 3;\nmatch (x) { 1 => 1, 2 => 2 }")
+	require.Equal(t, "ERROR: 2:1: no match for 3", evaluated.Inspect())
+
+	evaluated = testEval("match (1) { x if -true => x }")
+	require.Equal(t, "ERROR: 1:18: unknown operator: -BOOLEAN", evaluated.Inspect())
+}
+
+func TestNonStrictMatch(t *testing.T) {
+	evaluator.StrictMatch = false
+	defer func() { evaluator.StrictMatch = true }()
+
+	testNullObject(t, testEval("match (3) { 1 => 1 }"), "")
+	testIntegerObject(t, testEval("match (1) { 1 => 1 }"), 1, "")
+}
+
+func TestLetPatternMismatch(t *testing.T) {
+	evaluated := testEval("let [a, 2] = [1, 3];")
+	require.Equal(t, "ERROR: 1:9: value does not match 2: got=3", evaluated.Inspect())
+	testIntegerObject(t, testEval("let [_, b, _] = [1, 2, 3]; b"), 2, "")
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "5"},
+		{"quote(5 + 8)", "(5 + 8)"},
+		{"quote(foobar + barfoo)", "(foobar + barfoo)"},
+		{"quote(unquote(4))", "4"},
+		{"quote(unquote(4 + 4))", "8"},
+		{"quote(8 + unquote(4 + 4))", "(8 + 8)"},
+		{"quote(unquote(4 + 4) + 8)", "(8 + 8)"},
+		{"let foobar = 8; quote(foobar)", "foobar"},
+		{"let foobar = 8; quote(unquote(foobar))", "8"},
+		{"quote(unquote(true))", "true"},
+		{"quote(unquote(true == false))", "false"},
+		{"quote(unquote(1.5) + unquote(\"s\"))", `(1.5 + "s")`},
+		{"quote(unquote(quote(4 + 4)))", "(4 + 4)"},
+		{"let quotedInfix = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfix))", "(8 + (4 + 4))"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.IsType(t, new(object.Quote), evaluated, tt.input)
+		require.Equal(t, tt.expected, evaluated.(*object.Quote).Node.String(), tt.input)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(1, 2)", "wrong number of arguments to `quote`. got=2, want=1"},
+		{"quote(unquote(1, 2))", "wrong number of arguments to `unquote`. got=2, want=1"},
+		{"quote(unquote([1]))", "cannot unquote ARRAY"},
+		{"quote(unquote(x))", "identifier not found: x"},
+		{"quote(unquote(...[1]))", "`unquote` does not accept spread arguments"},
+		{"quote(unquote(k: 1))", "`unquote` does not accept keyword arguments"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		require.IsType(t, new(object.Error), evaluated, tt.input)
+		require.Equal(t, tt.expected, evaluated.(*object.Error).Message, tt.input)
+	}
+}
+
+func testExpandMacros(input string) (ast.Node, *object.Error) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	return evaluator.ExpandMacros(program, env)
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+
+	require.Equal(t, 2, len(program.Statements), "wrong number of statements")
+	_, ok := env.Get("number")
+	require.False(t, ok, "number should not be defined")
+	_, ok = env.Get("function")
+	require.False(t, ok, "function should not be defined")
+
+	obj, ok := env.Get("mymacro")
+	require.True(t, ok, "macro not in environment")
+	macro, ok := obj.(*object.Macro)
+	require.True(t, ok, "object is not Macro")
+	require.Equal(t, []string{"x", "y"}, []string{macro.Parameters[0].Value, macro.Parameters[1].Value})
+	require.Equal(t, "(x + y)", macro.Body.String())
+	require.Equal(t, "macro mymacro(x, y) {\n(x + y)\n}", macro.Inspect())
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected, _ := testExpandMacros(tt.expected)
+		expanded, err := testExpandMacros(tt.input)
+		require.Nil(t, err, tt.input)
+		require.Equal(t, expected.String(), expanded.String(), tt.input)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro(x) { x }; m(1, 2)", "ERROR: 1:25: wrong number of arguments to macro `m`. got=2, want=1"},
+		{"let m = macro(x) { 1 }; m(2)", "ERROR: 1:25: macro `m` must return a quote"},
+		{"let m = macro(x) { -true }; m(2)", "ERROR: 1:20: unknown operator: -BOOLEAN (in m)"},
+		{"let m = macro(x) { x }; m(x: 2)", "ERROR: 1:27: macros do not accept keyword arguments"},
+		{"let m = macro(x) { x }; let y = m(...[1]); puts(y)", "ERROR: 1:35: macros do not accept spread arguments"},
+	}
+
+	for _, tt := range errors {
+		_, err := testExpandMacros(tt.input)
+		require.NotNil(t, err, tt.input)
+		require.Equal(t, tt.expected, err.Inspect(), tt.input)
+	}
+}
+
+func TestMacroEvaluation(t *testing.T) {
+	input := `
+	let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) { unquote(consequence) } else { unquote(alternative) });
+	};
+	let assert = macro(check) {
+		quote(if (unquote(check)) { true } else { "assertion failed" });
+	};
+	let calls = 0;
+	let x = unless(1 > 2, 10, calls += 1);
+	x + calls * 100 + (if (assert(x == 10)) { 1000 } else { 0 })
+	`
+
+	expanded, err := testExpandMacros(input)
+	require.Nil(t, err)
+	testIntegerObject(t, evaluator.Eval(expanded, object.NewEnvironment()), 1010, "")
+
+	evaluated := testEval("let m = macro() { quote(1) }; m")
+	require.Equal(t, "ERROR: 1:9: macro literals must be bound by a top-level let", evaluated.Inspect())
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(source), 0644))
+	}
+	return dir
+}
+
+// testEvalModules evaluates input in a program importing with modules.
+func testEvalModules(input string, modules *object.Modules) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment().WithModules(modules)
+
+	return evaluator.Eval(program, env)
+}
+
+func TestModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.monkey": `
+			export let pi = 3;
+			let secret = 1;
+			export fn twice(x) { add(x, x) }
+			export fn add(x, y) { x + y }
+			export let [one, two] = [1, 2];
+		`,
+		"lib/a.monkey":      `import "./b.monkey"; export let x = b["y"] + 1;`,
+		"lib/b.monkey":      `export let y = 41;`,
+		"cycle/c1.monkey":   `import "./c2.monkey";`,
+		"cycle/c2.monkey":   `import "./c3.monkey";`,
+		"cycle/c3.monkey":   `import "./c1.monkey";`,
+		"broken.monkey":     `let = 1;`,
+		"failing.monkey":    `export let x = 1;` + "\n" + `-true;`,
+		"other-name.monkey": `export let z = 5;`,
+		"macros.monkey": `
+			let unless = macro(cond, then, otherwise) { quote(if (!(unquote(cond))) { unquote(then) } else { unquote(otherwise) }) };
+			export let pick = fn(x) { unless(x > 0, 0, x) };
+		`,
+	})
+
+	modules := object.NewModules([]string{dir})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "math.monkey"; math["add"](math["pi"], 1)`, 4},
+		{`import "math.monkey" as m; let {add, pi, two} = m; add(pi, two)`, 5},
+		{`import "math.monkey"; math["twice"](math["one"])`, 2},
+		{`import "lib/a.monkey"; a["x"]`, 42},
+		{`import "other-name.monkey" as other; other["z"]`, 5},
+		{`import "macros.monkey"; macros["pick"](7) + macros["pick"](-7)`, 7},
+		{"import " + strconv.Quote(filepath.Join(dir, "lib/b.monkey")) + `; b["y"]`, 41},
+		{`import "math.monkey"; math["secret"]`, "module math does not export secret"},
+		{`const math = 1; import "math.monkey"; math`, "cannot redeclare constant math"},
+		{`import "math.monkey"; math[1]`, "module index must be a STRING, got INTEGER"},
+		{`import "math.monkey" as m; let {secret} = m;`, "key not found in module: secret"},
+		{`import "missing.monkey";`, `cannot find module "missing.monkey"`},
+		{`import "./lib/b.monkey";`, `cannot find module "./lib/b.monkey"`},
+		{`import "cycle/c1.monkey";`, "import cycle: c1.monkey -> c2.monkey -> c3.monkey -> c1.monkey"},
+		{`import "failing.monkey";`, "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalModules(tt.input, modules)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+
+	// a module is evaluated once, however many times it is imported
+	first := testEvalModules(`import "math.monkey"; math`, modules)
+	require.IsType(t, new(object.Module), first)
+	require.Same(t, first, testEvalModules(`import "math.monkey" as again; again`, modules))
+	require.Equal(t, "module math {add, one, pi, twice, two}", first.Inspect())
+
+	evaluated := testEvalModules(`import "failing.monkey";`, modules)
+	require.Equal(t, "ERROR: "+filepath.Join(dir, "failing.monkey")+":2:1: unknown operator: -BOOLEAN", evaluated.Inspect())
+
+	evaluated = testEvalModules(`import "broken.monkey";`, modules)
+	require.Equal(t, "ERROR: 1:1: syntax error in imported module: "+filepath.Join(dir, "broken.monkey")+
+		":1:5: expected next token to be IDENT, got = instead", evaluated.Inspect())
+
+	// the modules of a program are its own, another one sees a changed file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib/b.monkey"), []byte(`export let y = 1;`), 0644))
+	testIntegerObject(t, testEvalModules(`import "lib/b.monkey"; b["y"]`, modules), 41, "")
+	testIntegerObject(t, testEvalModules(`import "lib/b.monkey"; b["y"]`, object.NewModules([]string{dir})), 1, "")
+}
+
+func TestConstBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const x = 5; x", 5},
+		{"const [a, b = 2] = [1]; a + b", 3},
+		{"const x = 1; if (true) { let x = 2; x }", 2},
+		{"const x = 1; for (x in [2]) { x }; x", 1},
+		{"const x = 1; fn f() { let x = 3; x } f() + x", 4},
+		{"let x = 1; const x = 2; x", 2},
+		{"const x = 1; x = 2", "cannot assign to constant x"},
+		{"const x = 1; x += 1", "cannot assign to constant x"},
+		{"const x = 1; fn f() { x = 2 } f()", "cannot assign to constant x"},
+		{"const x = 1; let x = 2;", "cannot redeclare constant x"},
+		{"const x = 1; const x = 1;", "cannot redeclare constant x"},
+		{"const x = 1; let [y, x] = [1, 2];", "cannot redeclare constant x"},
+		{"const c = 1; c = -true", "cannot assign to constant c"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+
+	// function declarations are bound before the statements of a program
+	// run, so only a constant of an earlier program conflicts with them
+	env := object.NewEnvironment()
+	evaluator.Eval(parser.New(lexer.New("const f = 1;")).ParseProgram(), env)
+	evaluated := evaluator.Eval(parser.New(lexer.New("fn f() {}")).ParseProgram(), env)
+	require.Equal(t, "ERROR: 1:1: cannot redeclare constant f", evaluated.Inspect())
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let xs = freeze([1, 2]); xs[0]", 1},
+		{"let xs = [1, 2]; freeze(xs); xs[0] = 3", "cannot modify frozen array xs"},
+		{"let xs = freeze([1, [2]]); xs[1][0] = 3", "cannot modify frozen array (xs[1])"},
+		{`let h = freeze({"a": 1}); h["a"] += 1`, "cannot modify frozen hash h"},
+		{`let h = freeze({"a": [1]}); h["a"][0] = 2`, `cannot modify frozen array (h["a"])`},
+		{"let xs = [1]; let ys = xs; freeze(ys); xs[0] = 2", "cannot modify frozen array xs"},
+		{"let xs = [1]; let ys = freeze([xs]); xs[0] = 2", "cannot modify frozen array xs"},
+		{"let xs = freeze([1]); let ys = xs + [2]; len(ys)", "unknown operator: ARRAY + ARRAY"},
+		{"freeze(5)", 5},
+		{"freeze(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"let xs = [0]; xs[0] = xs; freeze(xs); len(xs)", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testExpectedObject(t, evaluated, tt.expected, tt.input)
+	}
+}
+
+func TestLargeLoop(t *testing.T) {
+	input := `
+	let i = 0;
+	let s = 0;
+	while (i < 100000) { s += i; i += 1; }
+	s`
+
+	testIntegerObject(t, testEval(input), 4999950000, "")
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedInspect string
+	}{
+		{"let a = 1;\nfoobar", "ERROR: 2:1: identifier not found: foobar"},
+		{"let a = 1;\n  a + true;", "ERROR: 2:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(x) {\n  -x\n};\nf(true)", "ERROR: 2:3: unknown operator: -BOOLEAN (in f)"},
+		{"len(1, 2)", "ERROR: 1:1: wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		require.IsType(t, new(object.Error), evaluated, "TestCase: "+tt.input)
+		require.Equal(t, tt.expectedInspect, evaluated.Inspect(), "TestCase: "+tt.input)
+	}
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`hello`", "hello"},
+		{"let name = \"monkey\"; `hello ${name}!`", "hello monkey!"},
+		{"`${1 + 2} ${1.5} ${true} ${[1, \"a\"]}`", "3 1.5 true [1, a]"},
+		{"let f = fn(x) { x * 2 }; `${f(2)}${f(3)}`", "46"},
+		{"`a ${`b ${\"c\"}`}`", "a b c"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		require.IsType(t, new(object.String), evaluated, "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, evaluated.(*object.String).Value, "TestCase: "+tt.input)
+	}
+
+	evaluated := testEval("`a ${-true} b`")
+	require.IsType(t, new(object.Error), evaluated)
+	require.Equal(t, "unknown operator: -BOOLEAN", evaluated.(*object.Error).Message)
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	input := `let grüße = "你好"; grüße + ", Welt"`
+
+	evaluated := testEval(input)
+	require.IsType(t, new(object.String), evaluated, "object is not String")
+	require.Equal(t, "你好, Welt", evaluated.(*object.String).Value)
+}
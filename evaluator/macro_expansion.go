@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// DefineMacros binds the macros defined by the top-level let statements of
+// program in env and removes these statements from the program.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, stmt := range program.Statements {
+		if isMacroDefinition(stmt) {
+			addMacro(stmt, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	for i := len(definitions) - 1; i >= 0; i-- {
+		at := definitions[i]
+		program.Statements = append(program.Statements[:at], program.Statements[at+1:]...)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStmt, ok := node.(*ast.LetStatement)
+	if !ok || letStmt.Name == nil {
+		return false
+	}
+
+	_, ok = letStmt.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStmt := stmt.(*ast.LetStatement)
+	macroLiteral := letStmt.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Name:       letStmt.Name.Value,
+		Parameters: macroLiteral.Parameters,
+		Body:       macroLiteral.Body,
+		Env:        env,
+	}
+
+	env.Set(letStmt.Name.Value, macro)
+}
+
+// ExpandMacros replaces the calls to the macros defined in env within
+// program by the syntax they return. The arguments of a call are passed
+// to the macro quoted, as they are written. It returns the error raised by
+// the first macro that fails, if any.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+
+		evalEnv, e := extendMacroEnv(macro, call)
+		if e != nil {
+			err = e
+			return node
+		}
+
+		evaluated := unwrapReturnValue(evalBlockStatements(macro.Body.Statements, evalEnv))
+		switch result := evaluated.(type) {
+		case *object.Quote:
+			return result.Node
+		case *object.Error:
+			err = result
+			if err.Function == "" {
+				err.Function = macro.Name
+			}
+		default:
+			err = newError("macro `%s` must return a quote", macro.Name)
+			err.Pos = call.Pos()
+		}
+		return node
+	})
+
+	return expanded, err
+}
+
+func isMacroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+// extendMacroEnv binds the parameters of macro to the quoted arguments of
+// call in a new scope.
+func extendMacroEnv(macro *object.Macro, call *ast.CallExpression) (*object.Environment, *object.Error) {
+	for _, arg := range call.Arguments {
+		var err *object.Error
+		switch arg.(type) {
+		case *ast.KeywordArgument:
+			err = newError("macros do not accept keyword arguments")
+		case *ast.SpreadExpression:
+			err = newError("macros do not accept spread arguments")
+		default:
+			continue
+		}
+		err.Pos = arg.Pos()
+		return nil, err
+	}
+
+	if len(call.Arguments) != len(macro.Parameters) {
+		err := newError("wrong number of arguments to macro `%s`. got=%d, want=%d",
+			macro.Name, len(call.Arguments), len(macro.Parameters))
+		err.Pos = call.Pos()
+		return nil, err
+	}
+
+	extended := object.NewClosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		extended.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	return extended, nil
+}
@@ -0,0 +1,179 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+)
+
+func evalImportStatement(is *ast.ImportStatement, env *object.Environment) object.Object {
+	modules := env.Modules()
+
+	path, ok := findModule(is.Path.Value, is.Token.Pos.Filename, modules.SearchPath)
+	if !ok {
+		return newError("cannot find module %q", is.Path.Value)
+	}
+
+	module := importModule(path, modules)
+	if isError(module) {
+		return module
+	}
+
+	if !env.Declare(is.Name(), module, false) {
+		return redeclarationError(is.Name())
+	}
+	return nil
+}
+
+// findModule returns the absolute path of the file imported by name from
+// the file from, which is empty outside of a file. A name that is neither
+// absolute nor starts with ./ or ../ is searched in the directories of
+// searchPath in order, the others are found from the directory of from.
+func findModule(name, from string, searchPath []string) (string, bool) {
+	var candidates []string
+	switch {
+	case filepath.IsAbs(name):
+		candidates = []string{name}
+	case strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../"):
+		candidates = []string{filepath.Join(filepath.Dir(from), name)}
+	default:
+		for _, dir := range searchPath {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			if abs, err := filepath.Abs(path); err == nil {
+				return abs, true
+			}
+		}
+	}
+	return "", false
+}
+
+// importModule returns the module of the file at path, evaluating the file
+// the first time it is imported by the program of modules.
+func importModule(path string, modules *object.Modules) object.Object {
+	if module, ok := modules.Loaded[path]; ok {
+		return module
+	}
+
+	for i, p := range modules.Importing {
+		if p == path {
+			cycle := []string{}
+			for _, p := range modules.Importing[i:] {
+				cycle = append(cycle, filepath.Base(p))
+			}
+			cycle = append(cycle, filepath.Base(path))
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError("cannot import %q: %s", path, err)
+	}
+
+	p := parser.New(lexer.New(string(source)).WithFilename(path))
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) > 0 {
+		return newError("syntax error in imported module: %s", errs)
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, macroErr := ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		return macroErr
+	}
+
+	modules.Importing = append(modules.Importing, path)
+	defer func() { modules.Importing = modules.Importing[:len(modules.Importing)-1] }()
+
+	env := object.NewEnvironment().WithModules(modules)
+	if result := Eval(expanded, env); isError(result) {
+		return result
+	}
+
+	name := filepath.Base(path)
+	module := &object.Module{
+		Name:    strings.TrimSuffix(name, filepath.Ext(name)),
+		Path:    path,
+		Env:     env,
+		Exports: exportedNames(program),
+	}
+	modules.Loaded[path] = module
+
+	return module
+}
+
+// exportedNames returns the sorted names exported by program.
+func exportedNames(program *ast.Program) []string {
+	names := []string{}
+	for _, stmt := range program.Statements {
+		es, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		switch s := es.Statement.(type) {
+		case *ast.LetStatement:
+			if s.Pattern != nil {
+				names = append(names, patternNames(s.Pattern)...)
+			} else {
+				names = append(names, s.Name.Value)
+			}
+		case *ast.FunctionStatement:
+			names = append(names, s.Name.Value)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// patternNames returns the names bound by pattern.
+func patternNames(pattern ast.Pattern) []string {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return []string{pattern.Value}
+	case *ast.DefaultPattern:
+		return patternNames(pattern.Pattern)
+	case *ast.ArrayPattern:
+		names := []string{}
+		for _, el := range pattern.Elements {
+			names = append(names, patternNames(el)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Value)
+		}
+		return names
+	case *ast.HashPattern:
+		names := []string{}
+		for _, pair := range pattern.Pairs {
+			names = append(names, patternNames(pair.Value)...)
+		}
+		return names
+	}
+	return nil
+}
+
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	moduleObject := module.(*object.Module)
+
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module index must be a STRING, got %s", index.Type())
+	}
+
+	val, ok := moduleObject.Get(name.Value)
+	if !ok {
+		return newError("module %s does not export %s", moduleObject.Name, name.Value)
+	}
+	return val
+}
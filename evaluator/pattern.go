@@ -0,0 +1,186 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"monkey/ast"
+	"monkey/object"
+)
+
+// A mismatch is the result of matching a value against a pattern it does
+// not fit. It is a failure for let and a cue to try the next arm for match.
+type mismatch struct {
+	*object.Error
+}
+
+// bindPattern binds the parts of val to the names of pattern in env, a
+// value that does not fit the pattern is an error.
+func bindPattern(pattern ast.Pattern, val object.Object, env *object.Environment) object.Object {
+	result := matchPattern(pattern, val, env)
+	if m, ok := result.(*mismatch); ok {
+		return m.Error
+	}
+	return result
+}
+
+// matchPattern matches val against pattern, binding the names of pattern
+// in env along the way. It returns nil if val matches, a *mismatch if it
+// does not, or the error raised evaluating a default or a literal.
+//
+// A nil val stands for a missing array element or hash value, it takes the
+// default of the pattern, if any, which is evaluated in env.
+func matchPattern(pattern ast.Pattern, val object.Object, env *object.Environment) object.Object {
+	if def, ok := pattern.(*ast.DefaultPattern); ok {
+		if val == nil {
+			val = Eval(def.Default, env)
+			if isAbrupt(val) {
+				return val
+			}
+		}
+		pattern = def.Pattern
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		env.Set(pattern.Value, val)
+		return nil
+	case *ast.WildcardPattern:
+		return nil
+	case *ast.LiteralPattern:
+		return matchLiteralPattern(pattern, val, env)
+	case *ast.ArrayPattern:
+		return matchArrayPattern(pattern, val, env)
+	case *ast.HashPattern:
+		return matchHashPattern(pattern, val, env)
+	}
+
+	return newError("unknown pattern: %T", pattern)
+}
+
+func matchLiteralPattern(pattern *ast.LiteralPattern, val object.Object, env *object.Environment) object.Object {
+	literal := Eval(pattern.Value, env)
+	if isError(literal) {
+		return literal
+	}
+
+	if !literalEqual(literal, val) {
+		return mismatchf(pattern, "value does not match %s: got=%s", pattern, val.Inspect())
+	}
+	return nil
+}
+
+// literalEqual reports whether val is of the type of literal and has its
+// value, an integer does not match a float of the same value.
+func literalEqual(literal, val object.Object) bool {
+	switch literal := literal.(type) {
+	case *object.Integer:
+		val, ok := val.(*object.Integer)
+		return ok && val.Value == literal.Value
+	case *object.Float:
+		val, ok := val.(*object.Float)
+		return ok && val.Value == literal.Value
+	case *object.String:
+		val, ok := val.(*object.String)
+		return ok && val.Value == literal.Value
+	case *object.Boolean:
+		val, ok := val.(*object.Boolean)
+		return ok && val.Value == literal.Value
+	}
+	return false
+}
+
+func matchArrayPattern(pattern *ast.ArrayPattern, val object.Object, env *object.Environment) object.Object {
+	array, ok := val.(*object.Array)
+	if !ok {
+		return mismatchf(pattern, "cannot destructure %s with an array pattern", val.Type())
+	}
+
+	elements := array.Elements
+	if len(elements) > len(pattern.Elements) && pattern.Rest == nil {
+		return mismatchf(pattern, "too many elements to destructure: got=%d, want=%d",
+			len(elements), len(pattern.Elements))
+	}
+
+	for i, el := range pattern.Elements {
+		var elem object.Object
+		if i < len(elements) {
+			elem = elements[i]
+		} else if _, ok := el.(*ast.DefaultPattern); !ok {
+			return mismatchf(pattern, "not enough elements to destructure: got=%d, want=%s",
+				len(elements), wantElements(pattern))
+		}
+
+		if result := matchPattern(el, elem, env); result != nil {
+			return result
+		}
+	}
+
+	if pattern.Rest != nil {
+		rest := []object.Object{}
+		if len(elements) > len(pattern.Elements) {
+			rest = append(rest, elements[len(pattern.Elements):]...)
+		}
+		env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return nil
+}
+
+// wantElements describes the number of elements pattern destructures,
+// the elements after the last one without a default are optional.
+func wantElements(pattern *ast.ArrayPattern) string {
+	required := 0
+	for i, el := range pattern.Elements {
+		if _, ok := el.(*ast.DefaultPattern); !ok {
+			required = i + 1
+		}
+	}
+
+	switch {
+	case pattern.Rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required < len(pattern.Elements):
+		return fmt.Sprintf("%d to %d", required, len(pattern.Elements))
+	}
+	return fmt.Sprintf("%d", required)
+}
+
+func matchHashPattern(pattern *ast.HashPattern, val object.Object, env *object.Environment) object.Object {
+	var lookup func(key string) (object.Object, bool)
+	switch val := val.(type) {
+	case *object.Hash:
+		lookup = func(key string) (object.Object, bool) {
+			pair, ok := val.Pairs[(&object.String{Value: key}).HashKey()]
+			return pair.Value, ok
+		}
+	case *object.Module:
+		// the exports of a module destructure like the keys of a hash
+		lookup = val.Get
+	default:
+		return mismatchf(pattern, "cannot destructure %s with a hash pattern", val.Type())
+	}
+
+	for _, pair := range pattern.Pairs {
+		value, ok := lookup(pair.Key.Value)
+		if !ok {
+			if _, ok := pair.Value.(*ast.DefaultPattern); !ok {
+				return mismatchf(pair.Key, "key not found in %s: %s", strings.ToLower(string(val.Type())), pair.Key.Value)
+			}
+		}
+
+		if result := matchPattern(pair.Value, value, env); result != nil {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// mismatchf returns a mismatch positioned at the part of a pattern that
+// val did not fit.
+func mismatchf(node ast.Node, format string, a ...interface{}) *mismatch {
+	err := newError(format, a...)
+	err.Pos = node.Pos()
+	return &mismatch{err}
+}
@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote returns the syntax of node, with the unquote(...) calls within it
+// replaced by the syntax of the values of their arguments.
+func quote(node ast.Node, env *object.Environment) object.Object {
+	var err object.Object
+
+	node = ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil || !isUnquoteCall(call) {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			err = newError("wrong number of arguments to `unquote`. got=%d, want=1", len(call.Arguments))
+			return node
+		}
+
+		var argErr *object.Error
+		switch call.Arguments[0].(type) {
+		case *ast.KeywordArgument:
+			argErr = newError("`unquote` does not accept keyword arguments")
+		case *ast.SpreadExpression:
+			argErr = newError("`unquote` does not accept spread arguments")
+		}
+		if argErr != nil {
+			argErr.Pos = call.Arguments[0].Pos()
+			err = argErr
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted
+			return node
+		}
+
+		exp := convertObjectToASTNode(unquoted)
+		if exp == nil {
+			err = newError("cannot unquote %s", unquoted.Type())
+			return node
+		}
+		return exp
+	})
+
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// convertObjectToASTNode returns an expression evaluating to obj, or nil if
+// obj has no literal syntax.
+func convertObjectToASTNode(obj object.Object) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.New(token.INT, fmt.Sprintf("%d", obj.Value))
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Float:
+		t := token.New(token.FLOAT, strconv.FormatFloat(obj.Value, 'g', -1, 64))
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.Boolean{Token: token.New(token.TRUE, "true"), Value: true}
+		}
+		return &ast.Boolean{Token: token.New(token.FALSE, "false"), Value: false}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.New(token.STRING, obj.Value), Value: obj.Value}
+	case *object.Quote:
+		exp, _ := obj.Node.(ast.Expression)
+		return exp
+	}
+	return nil
+}
@@ -1,23 +1,57 @@
 package lexer
 
 import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"monkey/token"
 	"monkey/utils"
 )
 
+// An ErrorHandler is called for every lexical error the Lexer encounters.
+// Every ILLEGAL token is reported through it.
+type ErrorHandler func(pos token.Position, msg string)
+
 type Lexer struct {
-	input string
+	input    string // the whole input, unless streaming
+	filename string
+
+	reader    io.Reader // source of further input, nil once exhausted
+	readErr   error     // error that stopped reader, not reported yet
+	buf       []byte    // the input not yet discarded when streaming, see fill
+	base      int       // offset of buf[0] in the whole source
+	streaming bool      // the input is read from reader into buf
+	size      int       // length of the input held in memory, input or buf
+
+	idents map[string]token.Token // interned identifier and keyword tokens, see intern
 
 	endtoken *rune
+	errh     ErrorHandler
+	comments bool // return COMMENT tokens instead of skipping them
+
+	holes []int // open braces in each enclosing template hole
 
 	readPosition int
+	line         int
+	column       int
+	width        int // byte width of the last rune read, 0 at end of input
+
+	start token.Position // position of the token being read
 }
 
 func New(input string) *Lexer {
 	ch := rune(0)
 	return &Lexer{
 		input:    input,
+		size:     len(input),
 		endtoken: &ch,
+		idents:   make(map[string]token.Token),
+		line:     1,
+		column:   1,
 	}
 }
 
@@ -26,163 +60,716 @@ func (l *Lexer) WithEndToken(ch rune) *Lexer {
 	return l
 }
 
-func (l *Lexer) NextToken() *token.Token {
+// WithFilename sets the file name recorded in the position of every token.
+func (l *Lexer) WithFilename(filename string) *Lexer {
+	l.filename = filename
+	return l
+}
+
+// WithComments makes the Lexer return comments as COMMENT tokens,
+// by default they are skipped like white space.
+func (l *Lexer) WithComments() *Lexer {
+	l.comments = true
+	return l
+}
+
+// WithErrorHandler sets the handler that receives lexical errors.
+func (l *Lexer) WithErrorHandler(errh ErrorHandler) *Lexer {
+	l.errh = errh
+	return l
+}
+
+// NextToken returns the next token of the input. Once the input is
+// exhausted it keeps returning EOF tokens.
+func (l *Lexer) NextToken() token.Token {
 	if !l.hasNext() {
-		return nil
+		pos := l.position()
+		return token.Token{Type: token.EOF, Pos: pos, End: pos}
 	}
 
-	l.skipWhiteSpace()
+	for {
+		l.skipWhiteSpace()
 
-	ch := l.next()
+		l.start = l.position()
+		tok := l.readToken(l.next())
+		tok.Pos, tok.End = l.start, l.position()
+
+		if tok.IsNot(token.COMMENT) || l.comments {
+			return tok
+		}
+	}
+}
 
+func (l *Lexer) readToken(ch rune) token.Token {
 	switch ch {
 	case '=':
-		if l.peek() == '=' {
-			return token.New(token.EQ, string(ch)+string(l.next()))
+		switch l.peek() {
+		case '=':
+			return l.readOperator(token.EQ)
+		case '>':
+			return l.readOperator(token.ARROW)
 		}
-		return token.New(token.ASSIGN, string(ch))
+		return l.operator(token.ASSIGN)
 	case '!':
 		if l.peek() == '=' {
-			return token.New(token.NOT_EQ, string(ch)+string(l.next()))
+			return l.readOperator(token.NOT_EQ)
 		}
-		return token.New(token.BANG, string(ch))
+		return l.operator(token.BANG)
 	case '+':
-		return token.New(token.PLUS, string(ch))
+		if l.peek() == '=' {
+			return l.readOperator(token.PLUS_ASSIGN)
+		}
+		return l.operator(token.PLUS)
 	case '-':
-		return token.New(token.MINUS, string(ch))
+		if l.peek() == '=' {
+			return l.readOperator(token.MINUS_ASSIGN)
+		}
+		return l.operator(token.MINUS)
 	case '*':
-		return token.New(token.ASTERISK, string(ch))
+		switch l.peek() {
+		case '*':
+			return l.readOperator(token.POWER)
+		case '=':
+			return l.readOperator(token.ASTERISK_ASSIGN)
+		}
+		return l.operator(token.ASTERISK)
 	case '/':
-		return token.New(token.SLASH, string(ch))
+		switch l.peek() {
+		case '/':
+			return l.readLineComment()
+		case '*':
+			return l.readBlockComment()
+		case '=':
+			return l.readOperator(token.SLASH_ASSIGN)
+		}
+		return l.operator(token.SLASH)
+	case '%':
+		if l.peek() == '=' {
+			return l.readOperator(token.PERCENT_ASSIGN)
+		}
+		return l.operator(token.PERCENT)
 	case '<':
-		return token.New(token.LT, string(ch))
+		switch l.peek() {
+		case '=':
+			return l.readOperator(token.LT_EQ)
+		case '<':
+			return l.readOperator(token.SHIFT_LEFT)
+		}
+		return l.operator(token.LT)
 	case '>':
-		return token.New(token.GT, string(ch))
+		switch l.peek() {
+		case '=':
+			return l.readOperator(token.GT_EQ)
+		case '>':
+			return l.readOperator(token.SHIFT_RIGHT)
+		}
+		return l.operator(token.GT)
+	case '&':
+		if l.peek() == '&' {
+			return l.readOperator(token.AND)
+		}
+		return l.operator(token.BIT_AND)
+	case '|':
+		if l.peek() == '|' {
+			return l.readOperator(token.OR)
+		}
+		return l.operator(token.BIT_OR)
+	case '^':
+		return l.operator(token.BIT_XOR)
+	case '~':
+		return l.operator(token.BIT_NOT)
+	case '.':
+		if l.peek() == '.' {
+			l.next()
+			if l.peek() == '.' {
+				return l.readOperator(token.ELLIPSIS)
+			}
+		}
 	case ';':
-		return token.New(token.SEMICOLON, string(ch))
+		return l.operator(token.SEMICOLON)
 	case ',':
-		return token.New(token.COMMA, string(ch))
+		return l.operator(token.COMMA)
 	case ':':
-		return token.New(token.COLON, string(ch))
+		return l.operator(token.COLON)
 	case '{':
-		return token.New(token.LBRACE, string(ch))
+		if n := len(l.holes); n > 0 {
+			l.holes[n-1] += 1
+		}
+		return l.operator(token.LBRACE)
 	case '}':
-		return token.New(token.RBRACE, string(ch))
+		if n := len(l.holes); n > 0 {
+			if l.holes[n-1] == 0 {
+				// closes the hole, the template text continues
+				l.holes = l.holes[:n-1]
+				return l.readTemplate(false)
+			}
+			l.holes[n-1] -= 1
+		}
+		return l.operator(token.RBRACE)
 	case '(':
-		return token.New(token.LPAREN, string(ch))
+		return l.operator(token.LPAREN)
 	case ')':
-		return token.New(token.RPAREN, string(ch))
+		return l.operator(token.RPAREN)
 	case '[':
-		return token.New(token.LBRACKET, string(ch))
+		return l.operator(token.LBRACKET)
 	case ']':
-		return token.New(token.RBRACKET, string(ch))
+		return l.operator(token.RBRACKET)
 	case rune(0):
 		return token.New(token.EOF, string(ch))
-	case '"', '\'':
+	case '"':
+		if l.peek() == '"' {
+			l.next()
+			if l.peek() != '"' {
+				return token.New(token.STRING, "")
+			}
+			l.next()
+			return l.readRawString()
+		}
+		return l.readString(ch)
+	case '\'':
 		return l.readString(ch)
+	case '`':
+		return l.readTemplate(true)
 	default:
-		if !utils.IsLiteral(ch) {
-			return token.NewILLEGAL(string(ch))
-		}
 		if utils.IsLetter(ch) {
-			return l.readIdentifier(ch)
+			return l.readIdentifier()
 		}
 		if utils.IsDigit(ch) {
 			return l.readNumber(ch)
 		}
 	}
 
-	return token.NewILLEGAL(string(ch))
+	if !l.invalidRune(ch) {
+		l.errorf(l.start, "illegal character %q", ch)
+	}
+	return token.NewILLEGAL(l.literal(l.start.Offset))
+}
+
+// operator returns a token whose literal is spelled like its type,
+// operators and delimiters never allocate.
+func (l *Lexer) operator(ttype token.TokenType) token.Token {
+	return token.New(ttype, string(ttype))
+}
+
+// readOperator consumes the second character of a two character operator.
+func (l *Lexer) readOperator(ttype token.TokenType) token.Token {
+	l.next()
+	return l.operator(ttype)
+}
+
+func (l *Lexer) errorf(pos token.Position, format string, a ...interface{}) {
+	if l.errh != nil {
+		l.errh(pos, fmt.Sprintf(format, a...))
+	}
+}
+
+// invalidRune reports whether ch, the last rune read, stands for a byte
+// that is not valid UTF-8. Those are reported when they are read.
+func (l *Lexer) invalidRune(ch rune) bool {
+	return ch == utf8.RuneError && l.width == 1
 }
 
 func (l *Lexer) hasNext() bool {
-	return l.endtoken != nil || l.readPosition < len(l.input)
+	return l.endtoken != nil || l.more()
 }
 
 func (l *Lexer) peek() rune {
-	if l.readPosition == len(l.input) {
+	if !l.more() {
 		return *l.endtoken
 	}
-	return rune(l.input[l.readPosition])
+	if c := l.current(); c < utf8.RuneSelf {
+		return rune(c)
+	}
+	ch, _ := l.decode()
+	return ch
 }
 
 func (l *Lexer) next() rune {
-	if l.readPosition == len(l.input) {
+	if !l.more() {
 		ch := *l.endtoken
 		l.endtoken = nil
+		l.width = 0
 		return ch
 	}
-	ch := rune(l.input[l.readPosition])
-	l.readPosition += 1
+
+	ch, width := rune(l.current()), 1
+	if ch >= utf8.RuneSelf {
+		ch, width = l.decode()
+		if ch == utf8.RuneError && width == 1 {
+			l.errorf(l.position(), "invalid UTF-8 encoding")
+		}
+	}
+
+	l.readPosition += width
+	l.width = width
+	if ch == '\n' {
+		l.line += 1
+		l.column = 1
+	} else {
+		l.column += 1
+	}
 	return ch
 }
 
-func (l *Lexer) readString(ch rune) *token.Token {
-	str, state := "", 0
+func (l *Lexer) position() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.offset(),
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// offset returns the offset of the next character in the whole source.
+func (l *Lexer) offset() int {
+	return l.base + l.readPosition
+}
+
+// current returns the byte at the read position.
+func (l *Lexer) current() byte {
+	if l.streaming {
+		return l.buf[l.readPosition]
+	}
+	return l.input[l.readPosition]
+}
+
+// decode returns the rune at the read position and its width.
+func (l *Lexer) decode() (rune, int) {
+	if l.streaming {
+		return utf8.DecodeRune(l.buf[l.readPosition:])
+	}
+	return utf8.DecodeRuneInString(l.input[l.readPosition:])
+}
+
+// slice returns the input from the offset from up to the next character,
+// from must not lie before the start of the current token.
+func (l *Lexer) slice(from int) string {
+	return l.between(from, l.offset())
+}
+
+// between returns the input between the offsets from and to. It is a slice
+// of the input, or a copy of the window when streaming.
+func (l *Lexer) between(from, to int) string {
+	if l.streaming {
+		return string(l.buf[from-l.base : to-l.base])
+	}
+	return l.input[from-l.base : to-l.base]
+}
+
+// literal returns the input from the offset from up to the next character
+// as a token literal.
+func (l *Lexer) literal(from int) string {
+	return l.slice(from)
+}
+
+// maxIdents is the number of distinct identifiers interned by a Lexer, so
+// that the memory held by a Lexer does not grow with its input.
+const maxIdents = 1024
 
-	if ch == '"' {
-		state = 1
-	} else if ch == '\'' {
-		state = 2
+// intern returns the identifier or keyword token read from the offset
+// from, the literal of the first maxIdents identifiers is shared by every
+// occurrence.
+func (l *Lexer) intern(from int) token.Token {
+	var tok token.Token
+	var ok bool
+	if l.streaming {
+		// indexed by the conversion itself, which does not allocate
+		tok, ok = l.idents[string(l.buf[from-l.base:l.readPosition])]
 	} else {
-		return token.NewILLEGAL(str)
+		tok, ok = l.idents[l.input[from-l.base:l.readPosition]]
+	}
+	if ok {
+		return tok
 	}
 
-	for l.hasNext() {
+	tok = token.ParseIndent(l.literal(from))
+	if len(l.idents) < maxIdents {
+		l.idents[tok.Literal] = tok
+	}
+	return tok
+}
+
+// readString reads a string literal, the literal is a slice of the input
+// unless the string contains escape sequences.
+func (l *Lexer) readString(quote rune) token.Token {
+	var decoded *strings.Builder
+
+	valid, from := true, l.offset()
+	for l.more() {
+		pos := l.position()
 		ch := l.next()
-		switch state {
-		case 1:
-			if ch == '"' {
-				return token.New(token.STRING, str)
+		if ch == quote {
+			end := l.offset() - l.width
+			var str string
+			if decoded != nil {
+				decoded.WriteString(l.between(from, end))
+				str = decoded.String()
+			} else {
+				str = l.literal(from)
+				str = str[:len(str)-l.width]
+			}
+			if !valid {
+				// the offending characters have already been reported
+				return token.NewILLEGAL(str)
 			}
-		case 2:
-			if ch == '\'' {
-				return token.New(token.STRING, str)
+			return token.New(token.STRING, str)
+		}
+		if ch == '\\' {
+			if decoded == nil {
+				decoded = new(strings.Builder)
+			}
+			decoded.WriteString(l.between(from, pos.Offset))
+			if ch, ok := l.readEscape(pos); ok {
+				decoded.WriteRune(ch)
+			} else {
+				valid = false
 			}
+			from = l.offset()
+		} else if l.invalidRune(ch) {
+			valid = false
 		}
-		str += string(ch)
 	}
 
-	return token.New(token.ILLEGAL, str)
+	str := l.literal(from)
+	if decoded != nil {
+		decoded.WriteString(str)
+		str = decoded.String()
+	}
+
+	l.errorf(l.start, "string literal not terminated")
+	return token.NewILLEGAL(str)
 }
 
-func (l *Lexer) readIdentifier(ch rune) *token.Token {
-	str := string(ch)
-	for l.hasNext() {
-		ch := l.peek()
-		if !utils.IsLiteral(ch) {
-			break
+// readRawString reads a """-delimited string literal, the text up to the
+// closing """ is taken verbatim, escape sequences included. A raw string
+// starting with a newline and ending on a line of its own is trimmed, see
+// trimIndent.
+func (l *Lexer) readRawString() token.Token {
+	valid, from, quotes := true, l.offset(), 0
+	for l.more() {
+		ch := l.next()
+		if ch != '"' {
+			quotes = 0
+			if l.invalidRune(ch) {
+				valid = false
+			}
+			continue
+		}
+		if quotes++; quotes == 3 {
+			str := l.literal(from)
+			str = trimIndent(str[:len(str)-3])
+			if !valid {
+				// the offending characters have already been reported
+				return token.NewILLEGAL(str)
+			}
+			return token.New(token.RAW_STRING, str)
+		}
+	}
+
+	l.errorf(l.start, "raw string literal not terminated")
+	return token.NewILLEGAL(l.literal(from))
+}
+
+// trimIndent strips a multi-line raw string: when s starts with a newline
+// and its last line contains only blanks, both lines are removed and the
+// blanks of the last line are removed from the start of every other line.
+//
+//	let query = """
+//	    SELECT *
+//	      FROM t
+//	    """;
+//
+// yields "SELECT *\n  FROM t".
+func trimIndent(s string) string {
+	if !strings.HasPrefix(s, "\n") {
+		return s
+	}
+	i := strings.LastIndexByte(s, '\n')
+	indent := s[i+1:]
+	if strings.Trim(indent, " \t") != "" {
+		return s
+	}
+	if i == 0 {
+		return ""
+	}
+
+	lines := strings.Split(s[1:i], "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readTemplate reads template text up to the closing backtick or the next
+// ${ hole, head tells whether the text follows the opening backtick or
+// the } closing a hole.
+func (l *Lexer) readTemplate(head bool) token.Token {
+	var decoded *strings.Builder
+
+	valid, from := true, l.offset()
+	for l.more() {
+		pos := l.position()
+		ch := l.next()
+		if ch == '`' || (ch == '$' && l.peek() == '{') {
+			var str string
+			if decoded != nil {
+				decoded.WriteString(l.between(from, pos.Offset))
+				str = decoded.String()
+			} else {
+				str = l.literal(from)
+				str = str[:pos.Offset-from]
+			}
+
+			var ttype token.TokenType
+			switch {
+			case ch == '`' && head:
+				ttype = token.TEMPLATE
+			case ch == '`':
+				ttype = token.TEMPLATE_TAIL
+			case head:
+				ttype = token.TEMPLATE_HEAD
+			default:
+				ttype = token.TEMPLATE_MIDDLE
+			}
+			if ch == '$' {
+				l.next()
+				l.holes = append(l.holes, 0)
+			}
+
+			if !valid {
+				// the offending characters have already been reported
+				return token.NewILLEGAL(str)
+			}
+			return token.New(ttype, str)
+		}
+		if ch == '\\' {
+			if decoded == nil {
+				decoded = new(strings.Builder)
+			}
+			decoded.WriteString(l.between(from, pos.Offset))
+			if ch, ok := l.readEscape(pos); ok {
+				decoded.WriteRune(ch)
+			} else {
+				valid = false
+			}
+			from = l.offset()
+		} else if l.invalidRune(ch) {
+			valid = false
+		}
+	}
+
+	str := l.literal(from)
+	if decoded != nil {
+		decoded.WriteString(str)
+		str = decoded.String()
+	}
+
+	l.errorf(l.start, "template literal not terminated")
+	return token.NewILLEGAL(str)
+}
+
+// readEscape reads the escape sequence following a backslash at pos and
+// returns the character it stands for.
+func (l *Lexer) readEscape(pos token.Position) (rune, bool) {
+	if !l.more() {
+		// reported as an unterminated string literal
+		return 0, false
+	}
+
+	ch := l.next()
+	switch ch {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '\\', '"', '\'', '`', '$':
+		return ch, true
+	case 'x':
+		digits := l.readHexDigits(2)
+		if len(digits) != 2 {
+			l.errorf(pos, "invalid escape sequence \\x%s, want 2 hex digits", digits)
+			return 0, false
+		}
+		value, _ := strconv.ParseUint(digits, 16, 32)
+		return rune(value), true
+	case 'u':
+		if l.peek() != '{' {
+			l.errorf(pos, "invalid escape sequence \\u, want \\u{...}")
+			return 0, false
+		}
+		l.next()
+		digits := l.readHexDigits(6)
+		if len(digits) == 0 || l.peek() != '}' {
+			l.errorf(pos, "invalid escape sequence \\u{%s, want 1 to 6 hex digits and }", digits)
+			return 0, false
+		}
+		l.next()
+		value, _ := strconv.ParseUint(digits, 16, 32)
+		if value > unicode.MaxRune || (0xD800 <= value && value < 0xE000) {
+			l.errorf(pos, "escape sequence \\u{%s} is invalid Unicode code point", digits)
+			return 0, false
+		}
+		return rune(value), true
+	}
+
+	if !l.invalidRune(ch) {
+		l.errorf(pos, "unknown escape sequence \\%c", ch)
+	}
+	return 0, false
+}
+
+func (l *Lexer) readHexDigits(max int) string {
+	from := l.offset()
+	for l.offset()-from < max && utils.IsHexDigit(l.peek()) {
+		l.next()
+	}
+	return l.slice(from)
+}
+
+func (l *Lexer) readLineComment() token.Token {
+	for l.more() && l.peek() != '\n' {
+		l.next()
+	}
+	return token.New(token.COMMENT, l.literal(l.start.Offset))
+}
+
+// readBlockComment reads a block comment, block comments nest.
+func (l *Lexer) readBlockComment() token.Token {
+	l.next()
+
+	depth := 1
+	for l.more() {
+		ch := l.next()
+		switch {
+		case ch == '/' && l.peek() == '*':
+			l.next()
+			depth += 1
+		case ch == '*' && l.peek() == '/':
+			l.next()
+			depth -= 1
 		}
+		if depth == 0 {
+			return token.New(token.COMMENT, l.literal(l.start.Offset))
+		}
+	}
+
+	l.errorf(l.start, "comment not terminated")
+	return token.NewILLEGAL(l.literal(l.start.Offset))
+}
+
+func (l *Lexer) readIdentifier() token.Token {
+	for l.hasNext() && utils.IsLiteral(l.peek()) {
 		l.next()
-		str += string(ch)
 	}
-	return token.ParseIndent(str)
+	return l.intern(l.start.Offset)
 }
 
-func (l *Lexer) readNumber(ch rune) *token.Token {
-	str, state := string(ch), 0
+// readNumber reads an integer or a floating point number, states are
+// 0: integer part, 1: fraction, 2: exponent.
+func (l *Lexer) readNumber(ch rune) token.Token {
+	if ch == '0' {
+		switch l.peek() {
+		case 'x', 'X':
+			return l.readPrefixedInteger(utils.IsHexDigit)
+		case 'o', 'O':
+			return l.readPrefixedInteger(utils.IsOctalDigit)
+		case 'b', 'B':
+			return l.readPrefixedInteger(utils.IsBinaryDigit)
+		}
+	}
+
+	prev, state := ch, 0
 	for l.hasNext() {
 		ch := l.peek()
+		if ch == '_' {
+			l.next()
+			if !utils.IsDigit(prev) || !utils.IsDigit(l.peek()) {
+				return l.illegalNumber("'_' must separate successive digits in %q")
+			}
+			prev = ch
+			continue
+		}
+		if ch == 'e' || ch == 'E' {
+			if state == 2 {
+				break
+			}
+			state = 2
+			l.next()
+			if sign := l.peek(); sign == '+' || sign == '-' {
+				l.next()
+			}
+			if !utils.IsDigit(l.peek()) {
+				return l.illegalNumber("exponent has no digits in %q")
+			}
+			prev = ch
+			continue
+		}
 		if !utils.IsDigit(ch) && ch != '.' {
 			break
 		}
-		switch state {
-		case 0:
-			if ch == '.' {
-				state = 1
-			}
-		case 1:
-			if ch == '.' {
-				return token.NewILLEGAL(str + string(ch))
+		if ch == '.' {
+			l.next()
+			if state != 0 {
+				return l.illegalNumber("invalid number literal %q")
 			}
+			state = 1
+			prev = ch
+			continue
 		}
 		l.next()
-		str += string(ch)
+		prev = ch
 	}
 	if state == 0 {
-		return token.New(token.INT, str)
+		return token.New(token.INT, l.literal(l.start.Offset))
 	}
-	return token.New(token.FLOAT, str)
+	return token.New(token.FLOAT, l.literal(l.start.Offset))
+}
+
+// readPrefixedInteger reads the rest of a 0x, 0o or 0b integer literal,
+// isDigit reports the digits valid in its base.
+func (l *Lexer) readPrefixedInteger(isDigit func(rune) bool) token.Token {
+	l.next()
+
+	digits := 0
+	for l.hasNext() {
+		ch := l.peek()
+		if ch == '_' {
+			l.next()
+			if !isDigit(l.peek()) {
+				return l.illegalNumber("'_' must separate successive digits in %q")
+			}
+			continue
+		}
+		if !isDigit(ch) {
+			break
+		}
+		l.next()
+		digits += 1
+	}
+
+	if ch := l.peek(); utils.IsLiteral(ch) {
+		l.next()
+		l.errorf(l.start, "invalid digit %q in %q", ch, l.slice(l.start.Offset))
+		for utils.IsLiteral(l.peek()) {
+			l.next()
+		}
+		return token.NewILLEGAL(l.literal(l.start.Offset))
+	}
+	if digits == 0 {
+		return l.illegalNumber("%q has no digits")
+	}
+
+	return token.New(token.INT, l.literal(l.start.Offset))
+}
+
+// illegalNumber reports the number read so far with the message format
+// and returns it as an ILLEGAL token.
+func (l *Lexer) illegalNumber(format string) token.Token {
+	lit := l.literal(l.start.Offset)
+	l.errorf(l.start, format, lit)
+	return token.NewILLEGAL(lit)
 }
 
 func (l *Lexer) skipWhiteSpace() {
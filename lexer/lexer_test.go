@@ -1,7 +1,12 @@
 package lexer_test
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"monkey/lexer"
 	"monkey/token"
@@ -18,7 +23,7 @@ func TestNextToken(t *testing.T) {
         };
 
         let result = add(five, ten);
-        !-/*5;
+        !-/ *5;
         5 < 10 > 5;
 
         if (5 < 10) {
@@ -210,7 +215,7 @@ func TestNextToken3(t *testing.T) {
         };
 
         let result = add(five, ten);
-        !-/*5;
+        !-/ *5;
         5 < 10 > 5;
 
         if (5 < 10) {
@@ -318,3 +323,512 @@ func TestNextToken3(t *testing.T) {
 		require.Equalf(t, tt.exceptedLiteral, tok.Literal, "tests[%d] - literal wrong", i)
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 5;\n  x + 10;"
+
+	tests := []struct {
+		exceptedType   token.TokenType
+		exceptedPos    token.Position
+		exceptedEndCol int
+	}{
+		{token.LET, token.Position{Filename: "test.monkey", Offset: 0, Line: 1, Column: 1}, 4},
+		{token.IDENT, token.Position{Filename: "test.monkey", Offset: 4, Line: 1, Column: 5}, 6},
+		{token.ASSIGN, token.Position{Filename: "test.monkey", Offset: 6, Line: 1, Column: 7}, 8},
+		{token.INT, token.Position{Filename: "test.monkey", Offset: 8, Line: 1, Column: 9}, 10},
+		{token.SEMICOLON, token.Position{Filename: "test.monkey", Offset: 9, Line: 1, Column: 10}, 11},
+		{token.IDENT, token.Position{Filename: "test.monkey", Offset: 13, Line: 2, Column: 3}, 4},
+		{token.PLUS, token.Position{Filename: "test.monkey", Offset: 15, Line: 2, Column: 5}, 6},
+		{token.INT, token.Position{Filename: "test.monkey", Offset: 17, Line: 2, Column: 7}, 9},
+		{token.SEMICOLON, token.Position{Filename: "test.monkey", Offset: 19, Line: 2, Column: 9}, 10},
+		{token.EOF, token.Position{Filename: "test.monkey", Offset: 20, Line: 2, Column: 10}, 10},
+	}
+
+	l := lexer.New(input).WithFilename("test.monkey")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.exceptedType, tok.Type, "tests[%d] - tokentype wrong", i)
+		require.Equalf(t, tt.exceptedPos, tok.Pos, "tests[%d] - position wrong", i)
+		require.Equalf(t, tt.exceptedEndCol, tok.End.Column, "tests[%d] - end column wrong", i)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := `let grüße = "你好, Welt";
+	größe + 名字;`
+
+	tests := []struct {
+		exceptedType    token.TokenType
+		exceptedLiteral string
+		exceptedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "grüße", 5},
+		{token.ASSIGN, "=", 11},
+		{token.STRING, "你好, Welt", 13},
+		{token.SEMICOLON, ";", 23},
+		{token.IDENT, "größe", 2},
+		{token.PLUS, "+", 8},
+		{token.IDENT, "名字", 10},
+		{token.SEMICOLON, ";", 12},
+		{token.EOF, "\x00", 13},
+	}
+
+	l := lexer.New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.exceptedType, tok.Type, "tests[%d] - tokentype wrong", i)
+		require.Equalf(t, tt.exceptedLiteral, tok.Literal, "tests[%d] - literal wrong", i)
+		require.Equalf(t, tt.exceptedColumn, tok.Pos.Column, "tests[%d] - column wrong", i)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		input           string
+		exceptedType    token.TokenType
+		exceptedMessage string
+		exceptedPos     token.Position
+	}{
+		{"x \xff", token.ILLEGAL, "invalid UTF-8 encoding", token.Position{Offset: 2, Line: 1, Column: 3}},
+		{"\"ab\xc3\"", token.ILLEGAL, "invalid UTF-8 encoding", token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"\"abc", token.ILLEGAL, "string literal not terminated", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{"@", token.ILLEGAL, "illegal character '@'", token.Position{Offset: 0, Line: 1, Column: 1}},
+	}
+
+	for _, tt := range tests {
+		var messages []string
+		var positions []token.Position
+
+		l := lexer.New(tt.input).WithErrorHandler(func(pos token.Position, msg string) {
+			messages = append(messages, msg)
+			positions = append(positions, pos)
+		})
+
+		var tok token.Token
+		for tok = l.NextToken(); tok.Is(token.IDENT); tok = l.NextToken() {
+		}
+
+		require.Equal(t, tt.exceptedType, tok.Type, "TestCase: "+tt.input)
+		require.Equal(t, []string{tt.exceptedMessage}, messages, "TestCase: "+tt.input)
+		require.Equal(t, []token.Position{tt.exceptedPos}, positions, "TestCase: "+tt.input)
+		require.True(t, l.NextToken().IsEOF(), "TestCase: "+tt.input)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		exceptedType    token.TokenType
+		exceptedLiteral string
+		exceptedMessage string
+	}{
+		{`"a\nb\tc\rd"`, token.STRING, "a\nb\tc\rd", ""},
+		{`"\\ \" \'"`, token.STRING, `\ " '`, ""},
+		{`'it\'s'`, token.STRING, "it's", ""},
+		{`"\x41\x7e"`, token.STRING, "A~", ""},
+		{`"\u{48}\u{e9}\u{4f60}\u{1F600}"`, token.STRING, "Hé你😀", ""},
+		{`"a\qb"`, token.ILLEGAL, "ab", "1:3: unknown escape sequence \\q"},
+		{`"\x4"`, token.ILLEGAL, "", "1:2: invalid escape sequence \\x4, want 2 hex digits"},
+		{`"\u41"`, token.ILLEGAL, "41", "1:2: invalid escape sequence \\u, want \\u{...}"},
+		{`"\u{41"`, token.ILLEGAL, "", "1:2: invalid escape sequence \\u{41, want 1 to 6 hex digits and }"},
+		{`"\u{D800}"`, token.ILLEGAL, "", "1:2: escape sequence \\u{D800} is invalid Unicode code point"},
+		{`"abc\`, token.ILLEGAL, "abc", "1:1: string literal not terminated"},
+	}
+
+	for _, tt := range tests {
+		message := ""
+		l := lexer.New(tt.input).WithErrorHandler(func(pos token.Position, msg string) {
+			message = pos.String() + ": " + msg
+		})
+
+		tok := l.NextToken()
+		require.Equal(t, tt.exceptedType, tok.Type, "TestCase: "+tt.input)
+		require.Equal(t, tt.exceptedLiteral, tok.Literal, "TestCase: "+tt.input)
+		require.Equal(t, tt.exceptedMessage, message, "TestCase: "+tt.input)
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		exceptedType    token.TokenType
+		exceptedLiteral string
+	}{
+		{`""`, token.STRING, ""},
+		{`"""C:\dir\n"""`, token.RAW_STRING, `C:\dir\n`},
+		{`"""say "hi" ""`, token.ILLEGAL, `say "hi" ""`},
+		{"\"\"\"line 1\n  line 2\"\"\"", token.RAW_STRING, "line 1\n  line 2"},
+		{"\"\"\"\n    SELECT *\n      FROM t\n\n    \"\"\"", token.RAW_STRING, "SELECT *\n  FROM t\n"},
+		{"\"\"\"\n\t^\\d+$\n\t\"\"\"", token.RAW_STRING, `^\d+$`},
+		{"\"\"\"\n  a\n  b \"\"\"", token.RAW_STRING, "\n  a\n  b "},
+		{"\"\"\"\n  \"\"\"", token.RAW_STRING, ""},
+	}
+
+	for _, tt := range tests {
+		tok := lexer.New(tt.input).NextToken()
+		require.Equal(t, tt.exceptedType, tok.Type, "TestCase: "+tt.input)
+		require.Equal(t, tt.exceptedLiteral, tok.Literal, "TestCase: "+tt.input)
+	}
+
+	message := ""
+	l := lexer.New(`x = """abc`).WithErrorHandler(func(pos token.Position, msg string) {
+		message = pos.String() + ": " + msg
+	})
+	for tok := l.NextToken(); tok.IsNot(token.EOF); tok = l.NextToken() {
+	}
+	require.Equal(t, "1:5: raw string literal not terminated", message)
+}
+
+func TestTemplates(t *testing.T) {
+	input := "`a ${x} b ${ {\"k\": y}[\"k\"] } \\${c}\\``; `plain`"
+
+	tests := []struct {
+		exceptedType    token.TokenType
+		exceptedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.TEMPLATE_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, " ${c}`"},
+		{token.SEMICOLON, ";"},
+		{token.TEMPLATE, "plain"},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equal(t, tt.exceptedType, tok.Type, "TestCase: %d", i)
+		require.Equal(t, tt.exceptedLiteral, tok.Literal, "TestCase: %d", i)
+	}
+}
+
+func TestUnterminatedTemplate(t *testing.T) {
+	for _, input := range []string{"`abc", "`a ${x} b"} {
+		message := ""
+		l := lexer.New(input).WithErrorHandler(func(pos token.Position, msg string) {
+			message = pos.String() + ": " + msg
+		})
+
+		tok := l.NextToken()
+		for tok.IsNot(token.EOF) && tok.IsNot(token.ILLEGAL) {
+			tok = l.NextToken()
+		}
+		require.Equal(t, token.ILLEGAL, tok.Type, "TestCase: "+input)
+		require.Contains(t, message, "template literal not terminated", "TestCase: "+input)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+	let x = 5 / 2; // trailing
+	/* block /* nested */ still comment */ x`
+
+	tests := []struct {
+		exceptedType    token.TokenType
+		exceptedLiteral string
+	}{
+		{token.COMMENT, "// leading"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input).WithComments()
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.exceptedType, tok.Type, "tests[%d] - tokentype wrong", i)
+		require.Equalf(t, tt.exceptedLiteral, tok.Literal, "tests[%d] - literal wrong", i)
+	}
+
+	// comments are skipped by default
+	l = lexer.New(input)
+	for i, tt := range tests {
+		if tt.exceptedType == token.COMMENT {
+			continue
+		}
+		tok := l.NextToken()
+		require.Equalf(t, tt.exceptedType, tok.Type, "tests[%d] - tokentype wrong", i)
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	message := ""
+	l := lexer.New("x /* a /* b */").WithErrorHandler(func(pos token.Position, msg string) {
+		message = pos.String() + ": " + msg
+	})
+
+	require.True(t, l.NextToken().Is(token.IDENT))
+	require.True(t, l.NextToken().Is(token.ILLEGAL))
+	require.Equal(t, "1:3: comment not terminated", message)
+	require.True(t, l.NextToken().IsEOF())
+}
+
+func TestFloatNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		exceptedType    token.TokenType
+		exceptedLiteral string
+	}{
+		{"3.14", token.FLOAT, "3.14"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"1.5E+3", token.FLOAT, "1.5E+3"},
+		{"2e10", token.FLOAT, "2e10"},
+		{"1e", token.ILLEGAL, "1e"},
+		{"1e+", token.ILLEGAL, "1e+"},
+		{"1.2.3", token.ILLEGAL, "1.2."},
+		{"1e5.2", token.ILLEGAL, "1e5."},
+	}
+
+	for _, tt := range tests {
+		tok := lexer.New(tt.input).NextToken()
+		require.Equal(t, tt.exceptedType, tok.Type, "TestCase: "+tt.input)
+		require.Equal(t, tt.exceptedLiteral, tok.Literal, "TestCase: "+tt.input)
+	}
+}
+
+func TestIntegerNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		exceptedType    token.TokenType
+		exceptedLiteral string
+		exceptedMessage string
+	}{
+		{"0xFF", token.INT, "0xFF", ""},
+		{"0X1f", token.INT, "0X1f", ""},
+		{"0o755", token.INT, "0o755", ""},
+		{"0b1010", token.INT, "0b1010", ""},
+		{"1_000_000", token.INT, "1_000_000", ""},
+		{"0xFF_FF", token.INT, "0xFF_FF", ""},
+		{"0x_FF", token.INT, "0x_FF", ""},
+		{"1_000.5", token.FLOAT, "1_000.5", ""},
+		{"0x", token.ILLEGAL, "0x", `1:1: "0x" has no digits`},
+		{"0b", token.ILLEGAL, "0b", `1:1: "0b" has no digits`},
+		{"0b102", token.ILLEGAL, "0b102", `1:1: invalid digit '2' in "0b102"`},
+		{"0o8", token.ILLEGAL, "0o8", `1:1: invalid digit '8' in "0o8"`},
+		{"0xFG", token.ILLEGAL, "0xFG", `1:1: invalid digit 'G' in "0xFG"`},
+		{"100_", token.ILLEGAL, "100_", `1:1: '_' must separate successive digits in "100_"`},
+		{"1__0", token.ILLEGAL, "1_", `1:1: '_' must separate successive digits in "1_"`},
+		{"0xF_", token.ILLEGAL, "0xF_", `1:1: '_' must separate successive digits in "0xF_"`},
+		{"1._5", token.ILLEGAL, "1._", `1:1: '_' must separate successive digits in "1._"`},
+	}
+
+	for _, tt := range tests {
+		message := ""
+		l := lexer.New(tt.input).WithErrorHandler(func(pos token.Position, msg string) {
+			message = pos.String() + ": " + msg
+		})
+
+		tok := l.NextToken()
+		require.Equal(t, tt.exceptedType, tok.Type, "TestCase: "+tt.input)
+		require.Equal(t, tt.exceptedLiteral, tok.Literal, "TestCase: "+tt.input)
+		require.Equal(t, tt.exceptedMessage, message, "TestCase: "+tt.input)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := `a <= b >= c % d ** e && f || g & h | i ^ ~j << k >> l < m > n * o += p -= q *= r /= s %= t ...u => v`
+
+	tests := []struct {
+		exceptedType    token.TokenType
+		exceptedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.POWER, "**"},
+		{token.IDENT, "e"},
+		{token.AND, "&&"},
+		{token.IDENT, "f"},
+		{token.OR, "||"},
+		{token.IDENT, "g"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "h"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "i"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "j"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.IDENT, "k"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENT, "l"},
+		{token.LT, "<"},
+		{token.IDENT, "m"},
+		{token.GT, ">"},
+		{token.IDENT, "n"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "o"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.IDENT, "p"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.IDENT, "q"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.IDENT, "r"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.IDENT, "s"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.IDENT, "t"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "u"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "v"},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		require.Equalf(t, tt.exceptedType, tok.Type, "tests[%d] - tokentype wrong", i)
+		require.Equalf(t, tt.exceptedLiteral, tok.Literal, "tests[%d] - literal wrong", i)
+	}
+}
+
+func TestReader(t *testing.T) {
+	input := `let grüße = "你好, \u{1F600}"; /* comment */
+	let add = fn(x, y) { x + y; };
+	add(0x_FF, 1.5e3) <= 10 && "abc" != 'def';`
+
+	expected := []token.Token{}
+	for l := lexer.New(input).WithComments(); ; {
+		tok := l.NextToken()
+		expected = append(expected, tok)
+		if tok.IsEOF() {
+			break
+		}
+	}
+
+	readers := map[string]io.Reader{
+		"reader":     strings.NewReader(input),
+		"one byte":   iotest.OneByteReader(strings.NewReader(input)),
+		"half":       iotest.HalfReader(strings.NewReader(input)),
+		"data error": iotest.DataErrReader(strings.NewReader(input)),
+	}
+
+	for name, r := range readers {
+		l := lexer.NewReader(r).WithComments()
+		for i, tt := range expected {
+			tok := l.NextToken()
+			require.Equalf(t, tt, tok, "%s: tests[%d] - token wrong", name, i)
+		}
+		require.True(t, l.NextToken().IsEOF(), name)
+	}
+}
+
+func TestManyIdentifiers(t *testing.T) {
+	// more distinct identifiers than are interned, each followed by a
+	// keyword and a repeated identifier
+	var b strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&b, "x%d let x%d ", i, i/2)
+	}
+	input := b.String()
+
+	for name, l := range map[string]*lexer.Lexer{
+		"string": lexer.New(input),
+		"reader": lexer.NewReader(iotest.HalfReader(strings.NewReader(input))),
+	} {
+		for i := 0; i < 3000; i++ {
+			for _, want := range []token.Token{
+				token.New(token.IDENT, fmt.Sprintf("x%d", i)),
+				token.New(token.LET, "let"),
+				token.New(token.IDENT, fmt.Sprintf("x%d", i/2)),
+			} {
+				tok := l.NextToken()
+				require.Equal(t, want.Type, tok.Type, name)
+				require.Equal(t, want.Literal, tok.Literal, name)
+			}
+		}
+		require.True(t, l.NextToken().IsEOF(), name)
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	message := ""
+	r := io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(errors.New("boom")))
+	l := lexer.NewReader(r).WithErrorHandler(func(pos token.Position, msg string) {
+		message = pos.String() + ": " + msg
+	})
+
+	require.True(t, l.NextToken().Is(token.LET))
+	require.True(t, l.NextToken().Is(token.IDENT))
+	require.True(t, l.NextToken().IsEOF())
+	require.Equal(t, "1:6: read error: boom", message)
+}
+
+// benchmarkInput returns about size bytes of generated Monkey source, the
+// kind of data files the lexer has to load quickly.
+func benchmarkInput(size int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "let record_%d = {\"id\": %d, \"name\": \"item number %d\", \"price\": %d.25, \"tags\": [\"a\", \"b\\tc\"]};\n", i, i, i, i%1000)
+		fmt.Fprintf(&b, "let total = fn(x, y) { if (x >= y) { x * 2 } else { y - 1 } }; // running total\n")
+	}
+	return b.String()
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	input := benchmarkInput(4 << 20)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := lexer.New(input)
+		for !l.NextToken().IsEOF() {
+		}
+	}
+}
+
+func BenchmarkNextTokenReader(b *testing.B) {
+	input := benchmarkInput(4 << 20)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := lexer.NewReader(strings.NewReader(input))
+		for !l.NextToken().IsEOF() {
+		}
+	}
+}
+
+func BenchmarkNextTokenReaderLongToken(b *testing.B) {
+	input := `"` + strings.Repeat("x", 16<<20) + `"`
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := lexer.NewReader(strings.NewReader(input))
+		for !l.NextToken().IsEOF() {
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package lexer
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+const chunkSize = 64 * 1024
+
+// NewReader returns a Lexer that reads its input from r as needed.
+// Only the input of the token being read is kept in memory, so sources of
+// any size can be tokenized.
+func NewReader(r io.Reader) *Lexer {
+	l := New("")
+	l.reader = r
+	l.streaming = true
+	return l
+}
+
+// more reports whether there is input left, reading more of it if a
+// complete rune is not buffered yet.
+func (l *Lexer) more() bool {
+	if l.size-l.readPosition >= utf8.UTFMax {
+		// fast path, kept small enough to be inlined
+		return true
+	}
+	return l.moreSlow()
+}
+
+func (l *Lexer) moreSlow() bool {
+	if l.reader != nil {
+		l.fill()
+	}
+	if l.readPosition < l.size {
+		return true
+	}
+	if l.readErr != nil {
+		// reported where the input ends rather than where it was read
+		l.errorf(l.position(), "read error: %s", l.readErr)
+		l.readErr = nil
+	}
+	return false
+}
+
+// fill discards the input before the token being read, moving the rest to
+// the front of buf, and reads from the reader until a complete rune is
+// buffered. buf doubles when it is full, so that a long token is read in
+// linear time.
+func (l *Lexer) fill() {
+	if keep := l.start.Offset - l.base; keep > 0 {
+		n := copy(l.buf, l.buf[keep:])
+		l.buf = l.buf[:n]
+		l.size = n
+		l.readPosition -= keep
+		l.base += keep
+	}
+
+	for l.reader != nil && len(l.buf)-l.readPosition < utf8.UTFMax {
+		if len(l.buf) == cap(l.buf) {
+			size := 2 * cap(l.buf)
+			if size == 0 {
+				size = chunkSize
+			}
+			buf := make([]byte, len(l.buf), size)
+			copy(buf, l.buf)
+			l.buf = buf
+		}
+
+		n, err := l.reader.Read(l.buf[len(l.buf):cap(l.buf)])
+		l.buf = l.buf[:len(l.buf)+n]
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.reader = nil
+		}
+	}
+
+	l.size = len(l.buf)
+}
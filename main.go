@@ -1,14 +1,42 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
 )
 
+var traceParse = flag.Bool("trace-parse", false, "print a trace of the parse functions to stderr")
+var modulePath = flag.String("path", "", "directories searched for imported modules before the current one, separated by "+string(os.PathListSeparator))
+
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Runs the program in file, - for the standard input, or starts the REPL without a file.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var opts []parser.Option
+	if *traceParse {
+		opts = append(opts, parser.WithTrace(os.Stderr))
+	}
+	searchPath := []string{"."}
+	if *modulePath != "" {
+		searchPath = append(filepath.SplitList(*modulePath), searchPath...)
+	}
+	modules := object.NewModules(searchPath)
+
+	if flag.NArg() > 0 {
+		os.Exit(run(flag.Arg(0), modules, opts))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -17,5 +45,27 @@ func main() {
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Println("Feel free to type in commands")
 
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, modules, opts...)
+}
+
+// run runs the program in the file named filename, - for the standard
+// input, and returns the exit status of the interpreter.
+func run(filename string, modules *object.Modules, opts []parser.Option) int {
+	in := os.Stdin
+	if filename == "-" {
+		filename = ""
+	} else {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if !repl.Run(in, os.Stdout, filename, modules, opts...) {
+		return 1
+	}
+	return 0
 }
@@ -1,8 +1,11 @@
 package object
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store  map[string]Object
+	consts map[string]bool // the names bound by Declare as constants
+	outer  *Environment
+
+	modules *Modules // set on the outermost environment only, see Modules
 }
 
 func NewEnvironment() *Environment {
@@ -25,7 +28,84 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Assign updates the binding of name in the innermost environment that
+// defines it, it reports whether there was such a binding. It does not
+// check whether the binding is a constant, see IsConst.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Declare binds name to val in e, as a constant if constant is set. It
+// reports false and leaves e unchanged if e binds name to a constant
+// already.
+func (e *Environment) Declare(name string, val Object, constant bool) bool {
+	if e.consts[name] {
+		return false
+	}
+
+	e.store[name] = val
+	if constant {
+		if e.consts == nil {
+			e.consts = make(map[string]bool)
+		}
+		e.consts[name] = true
+	}
+	return true
+}
+
+// IsConst reports whether the innermost binding of name is a constant.
+func (e *Environment) IsConst(name string) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env.consts[name]
+		}
+	}
+	return false
+}
+
+// Modules is the state of the imports of a program, shared by its
+// environment and those of the modules it imports.
+type Modules struct {
+	SearchPath []string           // the directories searched for imported files, in order
+	Loaded     map[string]*Module // the evaluated modules by path
+	Importing  []string           // the paths of the modules being evaluated, outermost first
+}
+
+// NewModules returns the state of a program that has not imported any
+// module yet, whose imports are searched in searchPath.
+func NewModules(searchPath []string) *Modules {
+	return &Modules{
+		SearchPath: searchPath,
+		Loaded:     make(map[string]*Module),
+	}
+}
+
+// WithModules makes e, an outermost environment, share the module state m.
+func (e *Environment) WithModules(m *Modules) *Environment {
+	e.modules = m
+	return e
+}
+
+// Modules returns the module state of the outermost environment of e,
+// which searches the current directory unless set by WithModules.
+func (e *Environment) Modules() *Modules {
+	root := e
+	for root.outer != nil {
+		root = root.outer
+	}
+	if root.modules == nil {
+		root.modules = NewModules([]string{"."})
+	}
+	return root.modules
+}
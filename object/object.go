@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"monkey/ast"
+	"monkey/token"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -13,15 +17,21 @@ type BuiltinFunction func(args ...Object) Object
 
 const (
 	INTEGER_OBJ      ObjectType = "INTEGER"
+	FLOAT_OBJ        ObjectType = "FLOAT"
 	BOOLEAN_OBJ      ObjectType = "BOOLEAN"
 	NULL_OBJ         ObjectType = "NULL"
 	RETURN_VALUE_OBJ ObjectType = "RETURN_VALUE"
+	BREAK_OBJ        ObjectType = "BREAK"
+	CONTINUE_OBJ     ObjectType = "CONTINUE"
 	ERROR_OBJ        ObjectType = "ERROR"
 	FUNCTION_OBJ     ObjectType = "FUNCTION"
 	STRING_OBJ       ObjectType = "STRING"
 	BUILTIN_OBJ      ObjectType = "BUILTIN"
 	ARRAY_OBJ        ObjectType = "ARRAY"
 	HASH_OBJ         ObjectType = "HASH"
+	QUOTE_OBJ        ObjectType = "QUOTE"
+	MACRO_OBJ        ObjectType = "MACRO"
+	MODULE_OBJ       ObjectType = "MODULE"
 )
 
 type HashKey struct {
@@ -46,6 +56,26 @@ func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) HashKey() HashKey { return HashKey{i.Type(), uint64(i.Value)} }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) HashKey() HashKey {
+	if f.Value == 0 {
+		// -0.0 and 0.0 are equal and must hash the same
+		return HashKey{f.Type(), 0}
+	}
+	return HashKey{f.Type(), math.Float64bits(f.Value)}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -74,15 +104,41 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue are the results of the statements of the same name,
+// they unwind the enclosing blocks up to the loop.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
-	Message string
+	Message  string
+	Pos      token.Position // position of the node that raised the error
+	Function string         // name of the function the error was raised in, if any
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	msg := e.Message
+	if e.Function != "" {
+		msg += " (in " + e.Function + ")"
+	}
+	if !e.Pos.IsValid() {
+		return "ERROR: " + msg
+	}
+	return "ERROR: " + e.Pos.String() + ": " + msg
+}
 
 type Function struct {
+	Name       string // declared or let-bound name, if any
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -91,14 +147,14 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	literal := &ast.FunctionLiteral{Parameters: f.Parameters, Defaults: f.Defaults, Rest: f.Rest}
 
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(literal.ParameterList())
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -127,6 +183,7 @@ func (b *Builtin) Inspect() string  { return "builtin function" }
 
 type Array struct {
 	Elements []Object
+	Frozen   bool // set by freeze, the elements cannot be assigned
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -149,7 +206,8 @@ type HashPair struct {
 }
 
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool // set by freeze, the pairs cannot be assigned
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -168,3 +226,62 @@ func (h *Hash) Inspect() string {
 
 	return out.String()
 }
+
+// A Quote is the unevaluated syntax of an expression, the result of quote.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+type Macro struct {
+	Name       string // let-bound name
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	if m.Name != "" {
+		out.WriteString(" " + m.Name)
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// A Module is an imported file, it exposes the bindings it exports.
+type Module struct {
+	Name    string       // base name of the file without its extension
+	Path    string       // absolute path of the file
+	Env     *Environment // top-level scope of the file
+	Exports []string     // exported names, sorted
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+	return "module " + m.Name + " {" + strings.Join(m.Exports, ", ") + "}"
+}
+
+// Get returns the value bound to name if the module exports it.
+func (m *Module) Get(name string) (Object, bool) {
+	i := sort.SearchStrings(m.Exports, name)
+	if i == len(m.Exports) || m.Exports[i] != name {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
@@ -1,6 +1,7 @@
 package object_test
 
 import (
+	"math"
 	"testing"
 
 	"monkey/object"
@@ -62,3 +63,68 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &object.Float{Value: 0.5}
+	half2 := &object.Float{Value: 0.5}
+	zero := &object.Float{Value: 0}
+	negZero := &object.Float{Value: math.Copysign(0, -1)}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if zero.HashKey() != negZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	if half1.HashKey() == zero.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+		{1e-9, "1e-09"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, tt := range tests {
+		if got := (&object.Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("Inspect() wrong. got=%q, want=%q", got, tt.expected)
+		}
+	}
+}
+
+func TestEnvironmentDeclare(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	two := &object.Integer{Value: 2}
+
+	env := object.NewEnvironment()
+	if !env.Declare("x", one, false) || !env.Declare("x", two, true) {
+		t.Fatalf("declaring over a variable failed")
+	}
+	if env.Declare("x", one, false) {
+		t.Errorf("declaring over a constant succeeded")
+	}
+	if val, _ := env.Get("x"); val != two {
+		t.Errorf("constant changed, got=%s", val.Inspect())
+	}
+
+	inner := object.NewClosedEnvironment(env)
+	if !inner.IsConst("x") {
+		t.Errorf("constant of the enclosing environment not reported")
+	}
+	if !inner.Declare("x", one, false) || inner.IsConst("x") {
+		t.Errorf("shadowing a constant failed")
+	}
+	if env.IsConst("y") {
+		t.Errorf("unbound name reported as constant")
+	}
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"fmt"
+
+	"monkey/token"
+)
+
+// An ErrorCode classifies a parse error.
+type ErrorCode int
+
+const (
+	ErrSyntax            ErrorCode = iota + 1 // malformed token reported by the lexer
+	ErrUnexpectedToken                        // a token other than the expected ones
+	ErrNoPrefixParseFn                        // a token that cannot start an expression
+	ErrInvalidLiteral                         // a literal that does not fit its type
+	ErrMisplacedBranch                        // a break or continue outside of a loop
+	ErrInvalidAssignment                      // an assignment to anything but a variable or element
+	ErrInvalidParameters                      // a parameter list out of order
+	ErrInvalidArguments                       // an argument list out of order
+	ErrInvalidPattern                         // a destructuring pattern with a malformed element
+	ErrMisplacedImport                        // an import or export outside of the top level
+	ErrInvalidImport                          // an import without a usable module name
+)
+
+var errorCodes = [...]string{
+	ErrSyntax:            "syntax",
+	ErrUnexpectedToken:   "unexpected-token",
+	ErrNoPrefixParseFn:   "no-prefix-parse-fn",
+	ErrInvalidLiteral:    "invalid-literal",
+	ErrMisplacedBranch:   "misplaced-branch",
+	ErrInvalidAssignment: "invalid-assignment",
+	ErrInvalidParameters: "invalid-parameters",
+	ErrInvalidArguments:  "invalid-arguments",
+	ErrInvalidPattern:    "invalid-pattern",
+	ErrMisplacedImport:   "misplaced-import",
+	ErrInvalidImport:     "invalid-import",
+}
+
+func (c ErrorCode) String() string {
+	if 0 < c && int(c) < len(errorCodes) {
+		return errorCodes[c]
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// An Error describes a single parse error. Pos and End delimit the
+// offending source text, Got is the offending token unless the error was
+// reported by the lexer.
+type Error struct {
+	Pos      token.Position
+	End      token.Position
+	Code     ErrorCode
+	Expected []token.TokenType // the token types that would have been valid, if known
+	Got      token.Token
+	Msg      string
+}
+
+// Error returns the error in the "file:line:column: message" form.
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// ParseError is the list of errors found while parsing a program, in
+// source order.
+type ParseError []*Error
+
+// Error returns the first error and the number of the others.
+func (pe ParseError) Error() string {
+	switch len(pe) {
+	case 0:
+		return "no errors"
+	case 1:
+		return pe[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", pe[0], len(pe)-1)
+}
+
+// Err returns an error equivalent to the list, or nil if it is empty.
+func (pe ParseError) Err() error {
+	if len(pe) == 0 {
+		return nil
+	}
+	return pe
+}
+
+// Strings returns the messages of the errors as returned by Error.
+func (pe ParseError) Strings() []string {
+	msgs := make([]string, 0, len(pe))
+	for _, e := range pe {
+		msgs = append(msgs, e.Error())
+	}
+	return msgs
+}
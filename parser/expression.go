@@ -1,8 +1,6 @@
 package parser
 
 import (
-	"fmt"
-
 	"monkey/ast"
 	"monkey/token"
 )
@@ -10,26 +8,51 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGNMENT  // = or +=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BITWISE_OR  // |
+	BITWISE_XOR // ^
+	BITWISE_AND // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
-	PREFIX      // -Xor!X
+	PREFIX      // -X or !X or ~X
+	POWER       // **
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGNMENT,
+	token.PLUS_ASSIGN:     ASSIGNMENT,
+	token.MINUS_ASSIGN:    ASSIGNMENT,
+	token.ASTERISK_ASSIGN: ASSIGNMENT,
+	token.SLASH_ASSIGN:    ASSIGNMENT,
+	token.PERCENT_ASSIGN:  ASSIGNMENT,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.BIT_OR:          BITWISE_OR,
+	token.BIT_XOR:         BITWISE_XOR,
+	token.BIT_AND:         BITWISE_AND,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 func (p *Parser) currPrecedence() int {
@@ -47,14 +70,19 @@ func (p *Parser) nextPrecedence() int {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.untrace(p.trace("parseExpression"))
+
 	prefix := p.prefixParseFns[p.currToken.Type]
 	if prefix == nil {
-		p.errors = append(p.errors,
-			fmt.Sprintf("no prefix parse function for %s found", p.currToken.Type))
-		return nil
+		p.errorf(ErrNoPrefixParseFn, p.currToken, "no prefix parse function for %s found", p.currToken.Type)
+		return &ast.BadExpression{From: p.currToken.Pos, To: p.currToken.End}
 	}
 
+	from := p.currToken.Pos
 	leftExp := prefix()
+	if leftExp == nil {
+		return &ast.BadExpression{From: from, To: p.currToken.End}
+	}
 
 	for p.nextToken.IsNot(token.SEMICOLON) && precedence < p.nextPrecedence() {
 		infix := p.infixParseFns[p.nextToken.Type]
@@ -65,12 +93,24 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.getNextToken()
 
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			return &ast.BadExpression{From: from, To: p.currToken.End}
+		}
 	}
 
 	return leftExp
 }
 
+// parseIllegal skips an ILLEGAL token, the lexer has already reported it
+// as the error of the statement, whose follow-on errors are dropped.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.panicking = true
+	return &ast.BadExpression{From: p.currToken.Pos, To: p.currToken.End}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.untrace(p.trace("parsePrefixExpression"))
+
 	expression := &ast.PrefixExpression{
 		Token:    p.currToken,
 		Operator: p.currToken.Literal,
@@ -83,6 +123,8 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseInfixExpression"))
+
 	expression := &ast.InfixExpression{
 		Token:    p.currToken,
 		Operator: p.currToken.Literal,
@@ -90,13 +132,42 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.currPrecedence()
+	if p.currToken.Is(token.POWER) {
+		// ** is right associative
+		precedence -= 1
+	}
 	p.getNextToken()
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseAssignExpression"))
+
+	expression := &ast.AssignExpression{
+		Token:    p.currToken,
+		Target:   target,
+		Operator: p.currToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.errorf(ErrInvalidAssignment, p.currToken, "cannot assign to %s", target)
+		return nil
+	}
+
+	// assignments are right associative, a = b = c is a = (b = c)
+	p.getNextToken()
+	expression.Value = p.parseExpression(ASSIGNMENT - 1)
+
+	return expression
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.untrace(p.trace("parseGroupedExpression"))
+
 	p.getNextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -109,6 +180,8 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.untrace(p.trace("parseIfExpression"))
+
 	expression := &ast.IfExpression{Token: p.currToken}
 
 	// match (
@@ -138,6 +211,17 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.nextToken.Is(token.ELSE) {
 		p.getNextToken()
 
+		// else if chains nest the next if expression as the alternative
+		if p.nextToken.Is(token.IF) {
+			p.getNextToken()
+			alternative := p.parseIfExpression()
+			if alternative == nil {
+				return nil
+			}
+			expression.Alternative = alternative
+			return expression
+		}
+
 		if !p.expectNextToken(token.LBRACE) {
 			return nil
 		}
@@ -148,58 +232,191 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseMatchExpression parses a match expression, its arms are separated
+// by commas and are tried in order.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	defer p.untrace(p.trace("parseMatchExpression"))
+
+	expression := &ast.MatchExpression{Token: p.currToken}
+
+	if !p.expectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	p.getNextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	if !p.expectNextToken(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	for p.nextToken.IsNot(token.RBRACE) {
+		p.getNextToken()
+
+		arm := &ast.MatchArm{}
+		if arm.Pattern = p.parsePattern(); arm.Pattern == nil {
+			return nil
+		}
+
+		if p.nextToken.Is(token.IF) {
+			p.getNextToken()
+			p.getNextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectNextToken(token.ARROW) {
+			return nil
+		}
+
+		p.getNextToken()
+		arm.Body = p.parseExpression(LOWEST)
+
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.nextToken.IsNot(token.RBRACE) && !p.expectNextToken(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectNextToken(token.RBRACE) {
+		return nil
+	}
+	expression.Rbrace = p.currToken
+
+	return expression
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
-	fl := &ast.FunctionLiteral{Token: p.currToken}
+	defer p.untrace(p.trace("parseFunctionLiteral"))
+
+	if fl := p.parseFunction(p.currToken); fl != nil {
+		return fl
+	}
+	return nil
+}
+
+// parseMacroLiteral parses a macro literal, its parameters are plain names
+// as the arguments of a macro call are passed as they are written.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseMacroLiteral"))
+
+	fl := p.parseFunction(p.currToken)
+	if fl == nil {
+		return nil
+	}
+
+	for _, param := range fl.Parameters {
+		if _, ok := fl.Defaults[param.Value]; ok {
+			p.errorf(ErrInvalidParameters, param.Token, "macro parameter %s cannot have a default value", param.Value)
+			return nil
+		}
+	}
+	if fl.Rest != nil {
+		p.errorf(ErrInvalidParameters, fl.Rest.Token, "macro cannot have a rest parameter")
+		return nil
+	}
+
+	return &ast.MacroLiteral{Token: fl.Token, Parameters: fl.Parameters, Body: fl.Body}
+}
+
+// parseFunction parses the parameters and body of a function introduced
+// by the fn token tok.
+func (p *Parser) parseFunction(tok token.Token) *ast.FunctionLiteral {
+	fl := &ast.FunctionLiteral{Token: tok}
 
 	if !p.expectNextToken(token.LPAREN) {
 		return nil
 	}
 
-	fl.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(fl) {
+		return nil
+	}
 
 	if !p.expectNextToken(token.LBRACE) {
 		return nil
 	}
 
+	// break and continue cannot leave the function
+	loops := p.loops
+	p.loops = 0
 	fl.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return fl
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameters of fl up to the closing
+// parenthesis: names, then names with default values, then an optional
+// ...rest parameter.
+func (p *Parser) parseFunctionParameters(fl *ast.FunctionLiteral) bool {
+	defer p.untrace(p.trace("parseFunctionParameters"))
+
+	fl.Parameters = []*ast.Identifier{}
 
 	if p.nextToken.Is(token.RPAREN) {
 		p.getNextToken()
-		return identifiers
+		return true
 	}
 
-	p.getNextToken()
-
-	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-	identifiers = append(identifiers, ident)
+	for {
+		if p.nextToken.Is(token.ELLIPSIS) {
+			p.getNextToken()
+			if !p.expectNextToken(token.IDENT) {
+				return false
+			}
+			// the rest parameter comes last
+			fl.Rest = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+			break
+		}
 
-	for p.nextToken.Is(token.COMMA) {
-		p.getNextToken()
-		p.getNextToken()
+		if !p.expectNextToken(token.IDENT) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		fl.Parameters = append(fl.Parameters, ident)
+
+		if p.nextToken.Is(token.ASSIGN) {
+			p.getNextToken()
+			p.getNextToken()
+			if fl.Defaults == nil {
+				fl.Defaults = make(map[string]ast.Expression)
+			}
+			fl.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(fl.Defaults) > 0 {
+			p.errorf(ErrInvalidParameters, ident.Token, "missing default value for parameter %s", ident.Value)
+			return false
+		}
 
-	if !p.expectNextToken(token.RPAREN) {
-		return nil
+		if p.nextToken.IsNot(token.COMMA) {
+			break
+		}
+		p.getNextToken()
 	}
 
-	return identifiers
+	return p.expectNextToken(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseCallExpression"))
+
 	exp := &ast.CallExpression{Token: p.currToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseCallArguments()
+	if exp.Arguments == nil {
+		return nil
+	}
+	exp.Rparen = p.currToken
 	return exp
 }
 
+// parseCallArguments parses the arguments of a call up to the closing
+// parenthesis, the keyword arguments come after the positional ones.
 func (p *Parser) parseCallArguments() []ast.Expression {
+	defer p.untrace(p.trace("parseCallArguments"))
+
 	args := []ast.Expression{}
 
 	if p.nextToken.Is(token.RPAREN) {
@@ -207,15 +424,27 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		return args
 	}
 
-	// first args
-	p.getNextToken()
-	args = append(args, p.parseExpression(LOWEST))
-
-	for p.nextToken.Is(token.COMMA) {
-		p.getNextToken()
+	keywords := false
+	for {
 		p.getNextToken()
 
-		args = append(args, p.parseExpression(LOWEST))
+		if p.currToken.Is(token.IDENT) && p.nextToken.Is(token.COLON) {
+			name := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+			p.getNextToken()
+			p.getNextToken()
+			args = append(args, &ast.KeywordArgument{Name: name, Value: p.parseExpression(LOWEST)})
+			keywords = true
+		} else if keywords {
+			p.errorf(ErrInvalidArguments, p.currToken, "positional argument follows keyword argument")
+			return nil
+		} else {
+			args = append(args, p.parseListElement())
+		}
+
+		if p.nextToken.IsNot(token.COMMA) {
+			break
+		}
+		p.getNextToken()
 	}
 
 	if !p.expectNextToken(token.RPAREN) {
@@ -225,7 +454,23 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	return args
 }
 
+// parseListElement parses an element of an array literal or a positional
+// call argument, either an expression or a ...spread of an array.
+func (p *Parser) parseListElement() ast.Expression {
+	if p.currToken.IsNot(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.currToken}
+	p.getNextToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	defer p.untrace(p.trace("parseExpressionList"))
+
 	list := []ast.Expression{}
 
 	if p.nextToken.Is(end) {
@@ -234,12 +479,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.getNextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.nextToken.Is(token.COMMA) {
 		p.getNextToken()
 		p.getNextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectNextToken(end) {
@@ -250,6 +495,8 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.untrace(p.trace("parseIndexExpression"))
+
 	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
 
 	p.getNextToken()
@@ -259,6 +506,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectNextToken(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbrack = p.currToken
 
 	return exp
 }
@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"monkey/ast"
 	"monkey/token"
 	"strconv"
@@ -12,12 +11,29 @@ func (p *Parser) parseIdentifier() ast.Expression {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseIntegerLiteral"))
+
 	lit := &ast.IntegerLiteral{Token: p.currToken}
 
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
-		p.errors = append(p.errors,
-			fmt.Sprintf("could not parse %q as integer", p.currToken.Literal))
+		p.errorf(ErrInvalidLiteral, p.currToken, "could not parse %q as integer", p.currToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseFloatLiteral"))
+
+	lit := &ast.FloatLiteral{Token: p.currToken}
+
+	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
+	if err != nil {
+		p.errorf(ErrInvalidLiteral, p.currToken, "could not parse %q as float", p.currToken.Literal)
 		return nil
 	}
 
@@ -31,18 +47,61 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+	defer p.untrace(p.trace("parseStringLiteral"))
+
+	return &ast.StringLiteral{
+		Token: p.currToken,
+		Value: p.currToken.Literal,
+		Raw:   p.currToken.Is(token.RAW_STRING),
+	}
+}
+
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseTemplateLiteral"))
+
+	tmpl := &ast.TemplateLiteral{Token: p.currToken}
+	tmpl.Texts = append(tmpl.Texts, p.currToken.Literal)
+
+	if p.currToken.Is(token.TEMPLATE) {
+		return tmpl
+	}
+
+	p.getNextToken()
+	tmpl.Holes = append(tmpl.Holes, p.parseExpression(LOWEST))
+
+	for p.nextToken.Is(token.TEMPLATE_MIDDLE) {
+		p.getNextToken()
+		tmpl.Texts = append(tmpl.Texts, p.currToken.Literal)
+		p.getNextToken()
+		tmpl.Holes = append(tmpl.Holes, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectNextToken(token.TEMPLATE_TAIL) {
+		return nil
+	}
+	tmpl.Texts = append(tmpl.Texts, p.currToken.Literal)
+	tmpl.Tail = p.currToken
+
+	return tmpl
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseArrayLiteral"))
+
 	array := &ast.ArrayLiteral{Token: p.currToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	array.Rbrack = p.currToken
 
 	return array
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
+	defer p.untrace(p.trace("parseHashLiteral"))
+
 	hash := &ast.HashLiteral{Token: p.currToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
@@ -67,6 +126,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectNextToken(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.currToken
 
 	return hash
 }
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"io"
 
 	"monkey/ast"
 	"monkey/lexer"
@@ -14,33 +15,60 @@ type infixParseFn func(ast.Expression) ast.Expression
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []string
+	errors   ParseError
+	comments []*ast.CommentGroup
 
-	currToken *token.Token
-	nextToken *token.Token
+	// panic mode, set by the first parse error of a statement so that its
+	// follow-on errors are dropped until the parser synchronizes again
+	panicking bool
+	braces    int // unmatched '{' up to and including currToken
+
+	loops int // loops enclosing the current statement in its function
+
+	parsing bool // set by ParseProgram, outside of it errors never bail out
+
+	currToken token.Token
+	nextToken token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	tracer io.Writer // trace output, if any
+	indent int       // trace indentation level
 }
 
-func New(l *lexer.Lexer) *Parser {
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{
 		l:              l,
-		errors:         []string{},
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
 
+	l.WithComments().WithErrorHandler(func(pos token.Position, msg string) {
+		p.report(&Error{Pos: pos, End: pos, Code: ErrSyntax, Msg: msg})
+	})
+
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.RAW_STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE, p.parseTemplateLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseTemplateLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
@@ -52,6 +80,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
@@ -62,11 +107,33 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-func (p *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{}
+// maxErrors is the number of errors after which parsing is abandoned.
+const maxErrors = 10
+
+// bailout is panicked with to abandon parsing after maxErrors errors.
+type bailout struct{}
+
+func (p *Parser) ParseProgram() (program *ast.Program) {
+	program = &ast.Program{}
 	program.Statements = []ast.Statement{}
 
-	for p.currToken != nil && p.currToken.IsNot(token.EOF) {
+	defer func() {
+		p.parsing = false
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+		}
+		program.Comments = p.comments
+	}()
+
+	// the tokens read by New may already carry too many lexer errors
+	p.parsing = true
+	if len(p.errors) >= maxErrors {
+		panic(bailout{})
+	}
+
+	for p.currToken.IsNot(token.EOF) {
 		if stmt := p.parseStatement(); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -76,13 +143,37 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// Errors returns the messages of the errors found so far.
 func (p *Parser) Errors() []string {
+	return p.errors.Strings()
+}
+
+// ParseErrors returns the errors found so far in their structured form.
+func (p *Parser) ParseErrors() ParseError {
 	return p.errors
 }
 
 func (p *Parser) getNextToken() {
 	p.currToken = p.nextToken
 	p.nextToken = p.l.NextToken()
+
+	switch {
+	case p.currToken.Is(token.LBRACE):
+		p.braces++
+	case p.currToken.Is(token.RBRACE) && p.braces > 0:
+		p.braces--
+	}
+
+	// comments are collected on the side, they never reach the parse functions
+	var group *ast.CommentGroup
+	for p.nextToken.Is(token.COMMENT) {
+		if group == nil || group.End().Line+1 < p.nextToken.Pos.Line {
+			group = &ast.CommentGroup{}
+			p.comments = append(p.comments, group)
+		}
+		group.List = append(group.List, &ast.Comment{Token: p.nextToken, Text: p.nextToken.Literal})
+		p.nextToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) expectNextToken(ttype token.TokenType) bool {
@@ -91,11 +182,85 @@ func (p *Parser) expectNextToken(ttype token.TokenType) bool {
 		return true
 	}
 
-	p.errors = append(p.errors, fmt.Sprintf("expected next token to be %s, got %s instead",
-		ttype, p.nextToken.Type))
+	p.expectError(p.nextToken, ttype)
 	return false
 }
 
+// expectError reports that tok was found where a ttype token was expected.
+func (p *Parser) expectError(tok token.Token, ttype token.TokenType) {
+	p.report(&Error{
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Code:     ErrUnexpectedToken,
+		Expected: []token.TokenType{ttype},
+		Got:      tok,
+		Msg:      fmt.Sprintf("expected next token to be %s, got %s instead", ttype, tok.Type),
+	})
+}
+
+// errorf reports an error about the token tok.
+func (p *Parser) errorf(code ErrorCode, tok token.Token, format string, a ...interface{}) {
+	p.report(&Error{
+		Pos:  tok.Pos,
+		End:  tok.End,
+		Code: code,
+		Got:  tok,
+		Msg:  fmt.Sprintf(format, a...),
+	})
+}
+
+// report records err. Lexer errors are always recorded, the other errors
+// only if they are the first of their statement.
+func (p *Parser) report(err *Error) {
+	if err.Code != ErrSyntax {
+		if p.panicking {
+			return
+		}
+		p.panicking = true
+	}
+
+	p.errors = append(p.errors, err)
+	if len(p.errors) >= maxErrors && p.parsing {
+		panic(bailout{})
+	}
+}
+
+// synchronize skips the rest of a statement containing an error, up to its
+// ';' or until the next token starts a statement or closes the enclosing
+// block. depth is the number of braces enclosing the statement, the braces
+// opened within it are skipped as a whole.
+func (p *Parser) synchronize(depth int) {
+	for p.nextToken.IsNot(token.EOF) {
+		if p.braces <= depth {
+			if p.currToken.Is(token.SEMICOLON) {
+				break
+			}
+			if p.nextToken.Is(token.RBRACE) && depth > 0 {
+				break
+			}
+			if syncTokens[p.nextToken.Type] {
+				break
+			}
+		}
+		p.getNextToken()
+	}
+	p.panicking = false
+}
+
+// syncTokens are the tokens that start a statement.
+var syncTokens = map[token.TokenType]bool{
+	token.FUNCTION: true,
+	token.LET:      true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.IMPORT:   true,
+	token.EXPORT:   true,
+}
+
 func (p *Parser) registerPrefix(ttype token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[ttype] = fn
 }
@@ -2,11 +2,13 @@ package parser_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/parser"
+	"monkey/token"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -140,6 +142,90 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const limit = 10;", "const limit = 10;"},
+		{"const [a, b] = xs", "const [a, b] = xs;"},
+		{"export const max = 3;", "export const max = 3;"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		testProgramStatementCount(t, program, 1)
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+
+	p := parser.New(lexer.New("const f = fn() {};"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	require.True(t, stmt.Token.Is(token.CONST))
+	require.Equal(t, "f", stmt.Value.(*ast.FunctionLiteral).Name)
+}
+
+func TestLetPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs;"},
+		{"let [first, ...rest] = xs;", "let [first, ...rest] = xs;"},
+		{"let [...all] = xs;", "let [...all] = xs;"},
+		{"let [] = xs;", "let [] = xs;"},
+		{"let [a, b = a + 1] = xs;", "let [a, b = (a + 1)] = xs;"},
+		{"let {name, age: years} = person;", `let {"name": name, "age": years} = person;`},
+		{`let {"full name": name = "anon"} = person;`, `let {"full name": name = "anon"} = person;`},
+		{"let {pos: [x, y], tags: {first = 0}} = item;", `let {"pos": [x, y], "tags": {"first": first = 0}} = item;`},
+		{"let [{id}, [a, ...b]] = rows", `let [{"id": id}, [a, ...b]] = rows;`},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		testProgramStatementCount(t, program, 1)
+
+		letStmt, ok := program.Statements[0].(*ast.LetStatement)
+		require.True(t, ok, "program.Statements[0] is not ast.LetStatement")
+		require.Nil(t, letStmt.Name, "TestCase: "+tt.input)
+		require.NotNil(t, letStmt.Pattern, "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+}
+
+func TestInvalidLetPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [fn] = xs;", "1:6: expected a pattern, got FUNCTION instead"},
+		{"let [-a] = xs;", "1:6: expected a pattern, got - instead"},
+		{"let [...xs, y] = xs;", "1:11: expected next token to be ], got , instead"},
+		{"let [a b] = xs;", "1:8: expected next token to be ,, got IDENT instead"},
+		{"let {1: a} = h;", "1:6: expected a hash pattern key, got INT instead"},
+		{`let {"a"} = h;`, "1:9: expected next token to be :, got } instead"},
+		{"let {a: +} = h;", "1:9: expected a pattern, got + instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, p.Errors()[0], "TestCase: "+tt.input)
+		require.Equal(t, parser.ErrInvalidPattern == p.ParseErrors()[0].Code,
+			strings.Contains(tt.expected, "expected a"), "TestCase: "+tt.input)
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	input := `
         return 5;
@@ -222,6 +308,32 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testIntegerLiteral(t, stmt.Expression, 5)
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"0.25", 0.25},
+		{"1e-9", 1e-9},
+		{"2.5e3", 2500},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		testProgramStatementCount(t, program, 1)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		require.True(t, ok, "program.Statements[0] is not ast.ExpressionStatement")
+
+		float, ok := stmt.Expression.(*ast.FloatLiteral)
+		require.True(t, ok, "exp not *ast.FloatLiteral type")
+		require.Equal(t, tt.expected, float.Value)
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -230,6 +342,7 @@ func TestParsingPrefixExpression(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15", "-", 15},
+		{"~15", "~", 15},
 		{"!foobar;", "!", "foobar"},
 		{"-foobar;", "-", "foobar"},
 		{"!true;", "!", true},
@@ -270,6 +383,17 @@ func TestParsingInfixExpression(t *testing.T) {
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 >= 5", 5, ">=", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 ** 5", 5, "**", 5},
+		{"5 & 5", 5, "&", 5},
+		{"5 | 5", 5, "|", 5},
+		{"5 ^ 5", 5, "^", 5},
+		{"5 << 5", 5, "<<", 5},
+		{"5 >> 5", 5, ">>", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
 		{"foobar - barfoo;", "foobar", "-", "barfoo"},
 		{"foobar * barfoo;", "foobar", "*", "barfoo"},
@@ -410,6 +534,54 @@ func TestOperatorPrecedneceExpression(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a < b && b <= c",
+			"((a < b) && (b <= c))",
+		},
+		{
+			"a == b || a >= c",
+			"((a == b) || (a >= c))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"1 << 2 + 3",
+			"(1 << (2 + 3))",
+		},
+		{
+			"a % b * c",
+			"((a % b) * c)",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-2 ** 2",
+			"(-(2 ** 2))",
+		},
+		{
+			"2 * 3 ** 2",
+			"(2 * (3 ** 2))",
+		},
+		{
+			"~a & b",
+			"((~a) & b)",
+		},
 	}
 
 	for i, tt := range tests {
@@ -513,12 +685,191 @@ func TestIfElseExpression(t *testing.T) {
 	testIdentifier(t, consequence.Expression, "x")
 
 	// alternative
-	require.Equal(t, 1, len(exp.Alternative.Statements), "exp.Alternative.Statements count wrong.")
-	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	block, ok := exp.Alternative.(*ast.BlockStatement)
+	require.True(t, ok, "exp.Alternative is not *ast.BlockStatement")
+	require.Equal(t, 1, len(block.Statements), "exp.Alternative.Statements count wrong.")
+	alternative, ok := block.Statements[0].(*ast.ExpressionStatement)
 	require.True(t, ok, "alternative.Statements[0] is not ast.ExpressionStatement")
 	testIdentifier(t, alternative.Expression, "y")
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else if (z) { z } else { 0 }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 1)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	require.True(t, ok, "exp not *ast.IfExpression type")
+	testInfixExpression(t, exp.Condition, "x", "<", "y")
+
+	second, ok := exp.Alternative.(*ast.IfExpression)
+	require.True(t, ok, "exp.Alternative is not *ast.IfExpression")
+	testInfixExpression(t, second.Condition, "x", ">", "y")
+
+	third, ok := second.Alternative.(*ast.IfExpression)
+	require.True(t, ok, "second.Alternative is not *ast.IfExpression")
+	testIdentifier(t, third.Condition, "z")
+	require.IsType(t, new(ast.BlockStatement), third.Alternative)
+
+	require.Equal(t, "if(x < y) x else if(x > y) y else ifz z else 0", program.String())
+	require.Equal(t, len(input)+1, exp.End().Column)
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) { 0 => "zero", -1.5 => "neg", [a, _] if a > 0 => a, {kind: "dot", pos} => pos, _ => null, }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 1)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	require.True(t, ok, "exp not *ast.MatchExpression type")
+	testIdentifier(t, exp.Value, "x")
+	require.Equal(t, 5, len(exp.Arms))
+
+	require.IsType(t, new(ast.LiteralPattern), exp.Arms[0].Pattern)
+	require.IsType(t, new(ast.LiteralPattern), exp.Arms[1].Pattern)
+	require.IsType(t, new(ast.ArrayPattern), exp.Arms[2].Pattern)
+	testInfixExpression(t, exp.Arms[2].Guard, "a", ">", 0)
+	testIdentifier(t, exp.Arms[2].Body, "a")
+	require.IsType(t, new(ast.HashPattern), exp.Arms[3].Pattern)
+	require.Nil(t, exp.Arms[3].Guard)
+	require.IsType(t, new(ast.WildcardPattern), exp.Arms[4].Pattern)
+
+	require.Equal(t, `match (x) { 0 => "zero", -1.5 => "neg", [a, _] if (a > 0) => a, {"kind": "dot", "pos": pos} => pos, _ => null }`, program.String())
+	require.Equal(t, len(input)+1, exp.End().Column)
+}
+
+func TestInvalidMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match x { _ => 1 }", "1:7: expected next token to be (, got IDENT instead"},
+		{"match (x) { 1 + 2 => 3 }", "1:15: expected next token to be =>, got + instead"},
+		{"match (x) { _ => 1 _ => 2 }", "1:20: expected next token to be ,, got IDENT instead"},
+		{"match (x) { (a) => a }", "1:13: expected a pattern, got ( instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, p.Errors()[0], "TestCase: "+tt.input)
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = z + 1", "(x = (y = (z + 1)))"},
+		{"x += 2 * 3", "(x += (2 * 3))"},
+		{"a[0] = 1", "((a[0]) = 1)"},
+		{"h[\"k\"] %= x || y", "((h[\"k\"]) %= (x || y))"},
+		{"f(x = 1)", "f((x = 1))"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.CallExpression); !ok {
+			require.IsType(t, new(ast.AssignExpression), stmt.Expression, "TestCase: "+tt.input)
+		}
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+}
+
+func TestInvalidAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"a + b = c", "1:7: cannot assign to (a + b)"},
+		{"f() += 1", "1:5: cannot assign to f()"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.Equal(t, []string{tt.expected}, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, parser.ErrInvalidAssignment, p.ParseErrors()[0].Code)
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x; break; }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	require.True(t, ok, "program.Statements[0] is not ast.WhileStatement")
+	testInfixExpression(t, stmt.Condition, "x", "<", 10)
+	require.Equal(t, 2, len(stmt.Body.Statements), "body is not 2 statements")
+	require.IsType(t, new(ast.BreakStatement), stmt.Body.Statements[1])
+	require.Equal(t, "while(x < 10) xbreak;", program.String())
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { if (x == 1) { continue; } x }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	require.True(t, ok, "program.Statements[0] is not ast.ForStatement")
+	testIdentifier(t, stmt.Variable, "x")
+	require.Equal(t, "[1, 2]", stmt.Iterable.String())
+	require.Equal(t, 2, len(stmt.Body.Statements), "body is not 2 statements")
+	require.Equal(t, "for(x in [1, 2]) if(x == 1) continue;x", program.String())
+}
+
+func TestBranchOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"break;", []string{"1:1: break is not in a loop"}},
+		{"if (x) { continue }", []string{"1:10: continue is not in a loop"}},
+		{"while (x) { fn() { break; } }", []string{"1:20: break is not in a loop"}},
+		{"while (x) { for (y in x) { continue; } break; }", nil},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		if tt.expected == nil {
+			checkParserErrors(t, p)
+			continue
+		}
+		require.Equal(t, tt.expected, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, parser.ErrMisplacedBranch, p.ParseErrors()[0].Code)
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -546,6 +897,92 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestImportExportStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		expected string
+	}{
+		{`import "lib/math.monkey";`, "math", `import "lib/math.monkey";`},
+		{`import "./strings"`, "strings", `import "./strings";`},
+		{`import "lib/my-utils.monkey" as utils;`, "utils", `import "lib/my-utils.monkey" as utils;`},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		testProgramStatementCount(t, program, 1)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		require.True(t, ok, "program.Statements[0] is not ast.ImportStatement")
+		require.Equal(t, tt.name, stmt.Name(), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+
+	input := `export let x = 1; export fn f() { x } export let [a, b] = [1, 2];`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 3)
+	for _, stmt := range program.Statements {
+		require.IsType(t, new(ast.ExportStatement), stmt)
+	}
+	require.IsType(t, new(ast.FunctionStatement), program.Statements[1].(*ast.ExportStatement).Statement)
+	require.Equal(t, "export let x = 1;export fn f() xexport let [a, b] = [1, 2];", program.String())
+}
+
+func TestInvalidImportExport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		code     parser.ErrorCode
+	}{
+		{`fn f() { import "a"; }`, "1:10: import is only allowed at the top level", parser.ErrMisplacedImport},
+		{`if (true) { export let x = 1; }`, "1:13: export is only allowed at the top level", parser.ErrMisplacedImport},
+		{`import "my-lib.monkey";`, `1:8: cannot bind module "my-lib.monkey" to "my-lib", import it as a name`, parser.ErrInvalidImport},
+		{`import "lib/fn.monkey";`, `1:8: cannot bind module "lib/fn.monkey" to "fn", import it as a name`, parser.ErrInvalidImport},
+		{`import lib;`, "1:8: expected next token to be STRING, got IDENT instead", parser.ErrUnexpectedToken},
+		{`import "a" as "b";`, "1:15: expected next token to be IDENT, got STRING instead", parser.ErrUnexpectedToken},
+		{`export 1 + 2;`, "1:8: expected let, const or fn declaration after export, got INT instead", parser.ErrUnexpectedToken},
+		{`export fn() {};`, "1:8: expected let, const or fn declaration after export, got FUNCTION instead", parser.ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, p.Errors()[0], "TestCase: "+tt.input)
+		require.Equal(t, tt.code, p.ParseErrors()[0].Code, "TestCase: "+tt.input)
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	input := `fn add(x, y) { x + y } let sub = fn(x, y) { x - y }; fn(z) { z }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 3)
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	require.True(t, ok, "program.Statements[0] is not ast.FunctionStatement")
+	testIdentifier(t, stmt.Name, "add")
+	require.Equal(t, "add", stmt.Function.Name)
+	require.Equal(t, 2, len(stmt.Function.Parameters))
+	require.Equal(t, "fn add(x, y) (x + y)", stmt.String())
+
+	let := program.Statements[1].(*ast.LetStatement)
+	require.Equal(t, "sub", let.Value.(*ast.FunctionLiteral).Name)
+
+	anon := program.Statements[2].(*ast.ExpressionStatement)
+	require.Equal(t, "", anon.Expression.(*ast.FunctionLiteral).Name)
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -573,6 +1010,126 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionParameterForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		params   []string
+		defaults []string
+		rest     string
+		expected string
+	}{
+		{"fn(x, y = 10) {}", []string{"x", "y"}, []string{"y"}, "", "fn(x, y = 10) "},
+		{"fn(x = 1, y = x * 2) {}", []string{"x", "y"}, []string{"x", "y"}, "", "fn(x = 1, y = (x * 2)) "},
+		{"fn(first, ...rest) {}", []string{"first"}, nil, "rest", "fn(first, ...rest) "},
+		{"fn(...all) {}", []string{}, nil, "all", "fn(...all) "},
+		{"fn(a, b = [], ...c) {}", []string{"a", "b"}, []string{"b"}, "c", "fn(a, b = [], ...c) "},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		params := []string{}
+		for _, param := range function.Parameters {
+			params = append(params, param.Value)
+		}
+		require.Equal(t, tt.params, params, "TestCase: "+tt.input)
+		require.Equal(t, len(tt.defaults), len(function.Defaults), "TestCase: "+tt.input)
+		for _, name := range tt.defaults {
+			require.Contains(t, function.Defaults, name, "TestCase: "+tt.input)
+		}
+		if tt.rest == "" {
+			require.Nil(t, function.Rest, "TestCase: "+tt.input)
+		} else {
+			testIdentifier(t, function.Rest, tt.rest)
+		}
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+}
+
+func TestCallArgumentForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...xs)", "f(...xs)"},
+		{"f(1, ...xs, 2, ...[3])", "f(1, ...xs, 2, ...[3])"},
+		{"f(1, sep: \", \", end: x + 1)", "f(1, sep: \", \", end: (x + 1))"},
+		{"f(a: 1)", "f(a: 1)"},
+		{"[0, ...xs, ...ys]", "[0, ...xs, ...ys]"},
+		{"f({k: 1})", "f({k:1})"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+
+	p := parser.New(lexer.New("f(1, sep: 2)"))
+	call := p.ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	require.IsType(t, new(ast.IntegerLiteral), call.Arguments[0])
+	kwarg, ok := call.Arguments[1].(*ast.KeywordArgument)
+	require.True(t, ok, "call.Arguments[1] is not *ast.KeywordArgument")
+	testIdentifier(t, kwarg.Name, "sep")
+	testIntegerLiteral(t, kwarg.Value, 2)
+}
+
+func TestInvalidParametersAndArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x = 1, y) {}", "1:11: missing default value for parameter y"},
+		{"fn(...xs, y) {}", "1:9: expected next token to be ), got , instead"},
+		{"fn(1) {}", "1:4: expected next token to be IDENT, got INT instead"},
+		{"f(a: 1, 2)", "1:9: positional argument follows keyword argument"},
+		{"...xs", "1:1: no prefix parse function for ... found"},
+		{"macro(x = 1) { x }", "1:7: macro parameter x cannot have a default value"},
+		{"macro(x, ...ys) { x }", "1:13: macro cannot have a rest parameter"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		require.NotEmpty(t, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, p.Errors()[0], "TestCase: "+tt.input)
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	testProgramStatementCount(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok, "program.Statements[0] is not ast.ExpressionStatement.")
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	require.True(t, ok, "stmt.Expression is not ast.MacroLiteral")
+	require.Equal(t, 2, len(macro.Parameters), "macro literal parameters wrong")
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	require.Equal(t, 1, len(macro.Body.Statements), "macro.Body.Statements count wrong")
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok, "macro body stmt is not ast.ExpressionStatement")
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	require.Equal(t, "macro(x, y) (x + y)", program.String())
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	p := parser.New(lexer.New(input))
@@ -664,7 +1221,7 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 
 	for key, value := range hash.Pairs {
 		require.IsType(t, new(ast.StringLiteral), key)
-		expectedValue := expected[key.(*ast.StringLiteral).String()]
+		expectedValue := expected[key.(*ast.StringLiteral).Value]
 		testIntegerLiteral(t, value, expectedValue)
 	}
 }
@@ -696,8 +1253,272 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	for key, value := range hash.Pairs {
 		require.IsType(t, new(ast.StringLiteral), key)
 		literal, _ := key.(*ast.StringLiteral)
-		testFunc, ok := tests[literal.String()]
-		require.True(t, ok, "No test function for key", literal.String(), "found")
+		testFunc, ok := tests[literal.Value]
+		require.True(t, ok, "No test function for key", literal.Value, "found")
 		testFunc(value)
 	}
 }
+
+func TestNodePosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		pos      token.Position
+		end      token.Position
+	}{
+		{"foobar", "foobar", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 6, Line: 1, Column: 7}},
+		{"  a + b * c", "(a + (b * c))", token.Position{Offset: 2, Line: 1, Column: 3}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{"add(1,\n 2)", "add(1, 2)", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 10, Line: 2, Column: 4}},
+		{"[1, 2][0]", "([1, 2][0])", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{"if (x) {\n y\n}", "ifx y", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 13, Line: 3, Column: 2}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		testProgramStatementCount(t, program, 1)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		require.Equal(t, tt.expected, stmt.Expression.String())
+		require.Equal(t, tt.pos, stmt.Expression.Pos(), "TestCase: "+tt.input)
+		require.Equal(t, tt.end, stmt.Expression.End(), "TestCase: "+tt.input)
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+
+	p := parser.New(lexer.New(input).WithFilename("main.monkey"))
+	p.ParseProgram()
+
+	require.NotEmpty(t, p.Errors())
+	require.Equal(t, "main.monkey:2:5: expected next token to be IDENT, got = instead", p.Errors()[0])
+}
+
+func TestParseErrors(t *testing.T) {
+	input := "let x 5;\nlet y = 99999999999999999999;\nlet z = @;\n)"
+
+	p := parser.New(lexer.New(input).WithFilename("main.monkey"))
+	p.ParseProgram()
+
+	errs := p.ParseErrors()
+	require.Len(t, errs, 4)
+
+	require.Equal(t, parser.ErrUnexpectedToken, errs[0].Code)
+	require.Equal(t, []token.TokenType{token.ASSIGN}, errs[0].Expected)
+	require.Equal(t, token.INT, string(errs[0].Got.Type))
+	require.Equal(t, "main.monkey:1:7", errs[0].Pos.String())
+	require.Equal(t, "main.monkey:1:8", errs[0].End.String())
+
+	require.Equal(t, parser.ErrInvalidLiteral, errs[1].Code)
+	require.Equal(t, "99999999999999999999", errs[1].Got.Literal)
+
+	require.Equal(t, parser.ErrSyntax, errs[2].Code)
+	require.Equal(t, "main.monkey:3:9: illegal character '@'", errs[2].Error())
+
+	require.Equal(t, parser.ErrNoPrefixParseFn, errs[3].Code)
+	require.Equal(t, "no-prefix-parse-fn", errs[3].Code.String())
+
+	require.Equal(t, errs.Strings(), p.Errors())
+	require.EqualError(t, errs.Err(), "main.monkey:1:7: expected next token to be =, got INT instead (and 3 more errors)")
+	require.NoError(t, parser.ParseError(nil).Err())
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		errors   []string
+		expected string
+	}{
+		{
+			"let = 10;\nlet y = 2;",
+			[]string{"1:5: expected next token to be IDENT, got = instead"},
+			"<bad statement>let y = 2;",
+		},
+		{
+			"let x = (1 + 2;\nlet y = 2;\nx +;",
+			[]string{
+				"1:15: expected next token to be ), got ; instead",
+				"3:4: no prefix parse function for ; found",
+			},
+			"let x = <bad expression>;let y = 2;(x + <bad expression>)",
+		},
+		{
+			"let f = fn(x { return x; };\nf(1)",
+			[]string{"1:14: expected next token to be ), got { instead"},
+			"let f = <bad expression>;f(1)",
+		},
+		{
+			"if (x) {\n  let = 1;\n  x\n} else { y }\nz",
+			[]string{"2:7: expected next token to be IDENT, got = instead"},
+			"ifx <bad statement>x else yz",
+		},
+		{
+			"let h = {1 2, 3: 4};\nh",
+			[]string{"1:12: expected next token to be :, got INT instead"},
+			"let h = <bad expression>;h",
+		},
+		{
+			"fn() { 1",
+			[]string{"1:9: expected next token to be }, got EOF instead"},
+			"fn() 1",
+		},
+		{
+			"puts(1.5.2);\nx",
+			[]string{`1:6: invalid number literal "1.5."`},
+			"<bad expression>x",
+		},
+		{
+			"puts(1__0);\nx",
+			[]string{`1:6: '_' must separate successive digits in "1_"`},
+			"<bad expression>x",
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		require.Equal(t, tt.errors, p.Errors(), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+	}
+}
+
+func TestTooManyErrors(t *testing.T) {
+	input := strings.Repeat("let = 1;\n", 20)
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	require.Len(t, p.Errors(), 10)
+	require.Len(t, program.Statements, 9)
+
+	// the errors of the first token are found by New, before parsing starts
+	input = `"` + strings.Repeat(`\q`, 12) + `"`
+
+	p = parser.New(lexer.New(input))
+	program = p.ParseProgram()
+
+	require.Len(t, p.Errors(), 12)
+	require.Empty(t, program.Statements)
+}
+
+func TestTrace(t *testing.T) {
+	var out strings.Builder
+
+	p := parser.New(lexer.New("-a * b"), parser.WithTrace(&out))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := `1:1     BEGIN parseStatement "-"
+1:1       BEGIN parseExpressionStatement "-"
+1:1         BEGIN parseExpression "-"
+1:1           BEGIN parsePrefixExpression "-"
+1:2             BEGIN parseExpression "a"
+1:2             END parseExpression
+1:2           END parsePrefixExpression
+1:4           BEGIN parseInfixExpression "*"
+1:6             BEGIN parseExpression "b"
+1:6             END parseExpression
+1:6           END parseInfixExpression
+1:6         END parseExpression
+1:6       END parseExpressionStatement
+1:6     END parseStatement
+`
+	require.Equal(t, expected, out.String())
+}
+
+func TestLexerErrors(t *testing.T) {
+	input := "let x = \"caf\xe9\";\nlet y = 1 @ 2;"
+
+	p := parser.New(lexer.New(input))
+	p.ParseProgram()
+
+	require.Equal(t, []string{
+		"1:13: invalid UTF-8 encoding",
+		"2:11: illegal character '@'",
+	}, p.Errors())
+}
+
+func TestStringLiteralEscapes(t *testing.T) {
+	input := `"tab\there" + 'say \"hi\"\n'`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	infix, ok := stmt.Expression.(*ast.InfixExpression)
+	require.True(t, ok, "exp not *ast.InfixExpression type")
+	require.Equal(t, "tab\there", infix.Left.(*ast.StringLiteral).Value)
+	require.Equal(t, "say \"hi\"\n", infix.Right.(*ast.StringLiteral).Value)
+	require.Equal(t, `("tab\there" + "say \"hi\"\n")`, program.String())
+}
+
+func TestRawStringLiteral(t *testing.T) {
+	input := "let re = \"\"\"^\\d+\\.\\d*$\"\"\";\nlet sql = \"\"\"\n  SELECT 1\n  \"\"\";"
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	re := program.Statements[0].(*ast.LetStatement).Value.(*ast.StringLiteral)
+	require.True(t, re.Raw)
+	require.Equal(t, `^\d+\.\d*$`, re.Value)
+	sql := program.Statements[1].(*ast.LetStatement).Value.(*ast.StringLiteral)
+	require.Equal(t, "SELECT 1", sql.Value)
+	require.Equal(t, `let re = """^\d+\.\d*$""";let sql = """SELECT 1""";`, program.String())
+
+	p = parser.New(lexer.New(program.String()))
+	require.Equal(t, program.String(), p.ParseProgram().String())
+	checkParserErrors(t, p)
+}
+
+func TestTemplateLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		texts    []string
+		expected string
+	}{
+		{"`plain`", []string{"plain"}, "`plain`"},
+		{"`${a}`", []string{"", ""}, "`${a}`"},
+		{"`a ${x + 1} b ${f(y)} c`", []string{"a ", " b ", " c"}, "`a ${(x + 1)} b ${f(y)} c`"},
+		{"`\\${x} \\``", []string{"${x} `"}, "`\\${x} \\``"},
+		{"`outer ${`inner ${x}`}`", []string{"outer ", ""}, "`outer ${`inner ${x}`}`"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		tmpl, ok := stmt.Expression.(*ast.TemplateLiteral)
+		require.True(t, ok, "exp not *ast.TemplateLiteral type")
+		require.Equal(t, tt.texts, tmpl.Texts, "TestCase: "+tt.input)
+		require.Equal(t, len(tt.texts)-1, len(tmpl.Holes), "TestCase: "+tt.input)
+		require.Equal(t, tt.expected, program.String(), "TestCase: "+tt.input)
+		require.Equal(t, len(tt.input)+1, tmpl.End().Column, "TestCase: "+tt.input)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// Package doc,
+// second line.
+
+/* about x */
+let x = 5; // five
+x / 2`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	require.Equal(t, "let x = 5;(x / 2)", program.String())
+	require.Equal(t, 3, len(program.Comments))
+	require.Equal(t, "Package doc,\nsecond line.", program.Comments[0].Text())
+	require.Equal(t, "about x", program.Comments[1].Text())
+	require.Equal(t, "five", program.Comments[2].Text())
+	require.Equal(t, 5, program.Comments[2].Pos().Line)
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// parsePattern parses the pattern starting at the current token.
+func (p *Parser) parsePattern() ast.Pattern {
+	defer p.untrace(p.trace("parsePattern"))
+
+	switch p.currToken.Type {
+	case token.IDENT:
+		if p.currToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.currToken}
+		}
+		return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	case token.INT, token.FLOAT, token.STRING, token.RAW_STRING, token.TRUE, token.FALSE:
+		return p.parseLiteralPattern()
+	case token.MINUS:
+		if p.nextToken.Is(token.INT) || p.nextToken.Is(token.FLOAT) {
+			return p.parseLiteralPattern()
+		}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+
+	p.errorf(ErrInvalidPattern, p.currToken, "expected a pattern, got %s instead", p.currToken.Type)
+	return nil
+}
+
+// parseLiteralPattern parses a literal pattern, a literal alone rather than
+// an expression starting with it.
+func (p *Parser) parseLiteralPattern() ast.Pattern {
+	defer p.untrace(p.trace("parseLiteralPattern"))
+
+	if p.currToken.Is(token.MINUS) {
+		expression := &ast.PrefixExpression{Token: p.currToken, Operator: p.currToken.Literal}
+		p.getNextToken()
+		if expression.Right = p.prefixParseFns[p.currToken.Type](); expression.Right == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: expression}
+	}
+
+	value := p.prefixParseFns[p.currToken.Type]()
+	if value == nil {
+		return nil
+	}
+	return &ast.LiteralPattern{Value: value}
+}
+
+// parsePatternElement parses an element of an array or hash pattern, a
+// pattern optionally followed by = and its default value.
+func (p *Parser) parsePatternElement() ast.Pattern {
+	pattern := p.parsePattern()
+	if pattern == nil {
+		return nil
+	}
+
+	if p.nextToken.IsNot(token.ASSIGN) {
+		return pattern
+	}
+
+	p.getNextToken()
+	p.getNextToken()
+	def := p.parseExpression(LOWEST)
+	if def == nil {
+		return nil
+	}
+
+	return &ast.DefaultPattern{Pattern: pattern, Default: def}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	defer p.untrace(p.trace("parseArrayPattern"))
+
+	pattern := &ast.ArrayPattern{Token: p.currToken}
+
+	for p.nextToken.IsNot(token.RBRACKET) {
+		p.getNextToken()
+
+		if p.currToken.Is(token.ELLIPSIS) {
+			if !p.expectNextToken(token.IDENT) {
+				return nil
+			}
+			// the rest element comes last
+			pattern.Rest = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+			break
+		}
+
+		el := p.parsePatternElement()
+		if el == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, el)
+
+		if p.nextToken.IsNot(token.RBRACKET) && !p.expectNextToken(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectNextToken(token.RBRACKET) {
+		return nil
+	}
+	pattern.Rbrack = p.currToken
+
+	return pattern
+}
+
+// parseHashPattern parses a hash pattern, its keys are identifiers or
+// strings and {name} is short for {name: name}.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	defer p.untrace(p.trace("parseHashPattern"))
+
+	pattern := &ast.HashPattern{Token: p.currToken}
+
+	for p.nextToken.IsNot(token.RBRACE) {
+		p.getNextToken()
+
+		key := &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+		pair := &ast.HashPatternPair{Key: key}
+
+		switch {
+		case p.currToken.Is(token.STRING):
+			if !p.expectNextToken(token.COLON) {
+				return nil
+			}
+			p.getNextToken()
+		case p.currToken.Is(token.IDENT):
+			// without a colon the key is parsed again as the shorthand
+			if p.nextToken.Is(token.COLON) {
+				p.getNextToken()
+				p.getNextToken()
+			}
+		default:
+			p.errorf(ErrInvalidPattern, p.currToken, "expected a hash pattern key, got %s instead", p.currToken.Type)
+			return nil
+		}
+
+		if pair.Value = p.parsePatternElement(); pair.Value == nil {
+			return nil
+		}
+
+		pattern.Pairs = append(pattern.Pairs, pair)
+
+		if p.nextToken.IsNot(token.RBRACE) && !p.expectNextToken(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectNextToken(token.RBRACE) {
+		return nil
+	}
+	pattern.Rbrace = p.currToken
+
+	return pattern
+}
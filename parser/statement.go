@@ -1,30 +1,94 @@
 package parser
 
 import (
+	"unicode"
+
 	"monkey/ast"
 	"monkey/token"
 )
 
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.untrace(p.trace("parseStatement"))
+
+	from, depth := p.currToken, p.braces
+	if from.Is(token.LBRACE) {
+		depth--
+	}
+
+	var stmt ast.Statement
 	switch p.currToken.Type {
-	case token.LET:
-		return p.parseLetStatement()
+	case token.LET, token.CONST:
+		if s := p.parseLetStatement(); s != nil {
+			stmt = s
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if s := p.parseReturnStatement(); s != nil {
+			stmt = s
+		}
+	case token.WHILE:
+		if s := p.parseWhileStatement(); s != nil {
+			stmt = s
+		}
+	case token.FOR:
+		if s := p.parseForStatement(); s != nil {
+			stmt = s
+		}
+	case token.FUNCTION:
+		if p.nextToken.IsNot(token.IDENT) {
+			// an anonymous function literal
+			if s := p.parseExpressionStatement(); s != nil {
+				stmt = s
+			}
+			break
+		}
+		if s := p.parseFunctionStatement(); s != nil {
+			stmt = s
+		}
+	case token.BREAK, token.CONTINUE:
+		stmt = p.parseBranchStatement()
+	case token.IMPORT:
+		if s := p.parseImportStatement(); s != nil {
+			stmt = s
+		}
+	case token.EXPORT:
+		if s := p.parseExportStatement(); s != nil {
+			stmt = s
+		}
+	default:
+		if s := p.parseExpressionStatement(); s != nil {
+			stmt = s
+		}
 	}
-	return p.parseExpressionStatement()
+
+	if p.panicking {
+		p.synchronize(depth)
+		if stmt == nil {
+			stmt = &ast.BadStatement{From: from.Pos, To: p.currToken.End}
+		}
+	}
+
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	defer p.untrace(p.trace("parseLetStatement"))
+
 	stmt := &ast.LetStatement{Token: p.currToken}
 
-	if !p.expectNextToken(token.IDENT) {
-		return nil
-	}
+	if p.nextToken.Is(token.LBRACKET) || p.nextToken.Is(token.LBRACE) {
+		p.getNextToken()
+		if stmt.Pattern = p.parsePattern(); stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectNextToken(token.IDENT) {
+			return nil
+		}
 
-	stmt.Name = &ast.Identifier{
-		Token: p.currToken,
-		Value: p.currToken.Literal,
+		stmt.Name = &ast.Identifier{
+			Token: p.currToken,
+			Value: p.currToken.Literal,
+		}
 	}
 
 	if !p.expectNextToken(token.ASSIGN) {
@@ -34,6 +98,30 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.getNextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fl.Name = stmt.Name.Value
+	}
+
+	if p.nextToken.Is(token.SEMICOLON) {
+		p.getNextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	defer p.untrace(p.trace("parseFunctionStatement"))
+
+	stmt := &ast.FunctionStatement{Token: p.currToken}
+
+	p.getNextToken()
+	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	stmt.Function = p.parseFunction(stmt.Token)
+	if stmt.Function == nil {
+		return nil
+	}
+	stmt.Function.Name = stmt.Name.Value
 
 	if p.nextToken.Is(token.SEMICOLON) {
 		p.getNextToken()
@@ -43,6 +131,8 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.untrace(p.trace("parseReturnStatement"))
+
 	stmt := &ast.ReturnStatement{Token: p.currToken}
 
 	p.getNextToken()
@@ -56,7 +146,187 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	defer p.untrace(p.trace("parseWhileStatement"))
+
+	stmt := &ast.WhileStatement{Token: p.currToken}
+
+	if !p.expectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	p.getNextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectNextToken(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.nextToken.Is(token.SEMICOLON) {
+		p.getNextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	defer p.untrace(p.trace("parseForStatement"))
+
+	stmt := &ast.ForStatement{Token: p.currToken}
+
+	if !p.expectNextToken(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectNextToken(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	if !p.expectNextToken(token.IN) {
+		return nil
+	}
+
+	p.getNextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectNextToken(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectNextToken(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.nextToken.Is(token.SEMICOLON) {
+		p.getNextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	return p.parseBlockStatement()
+}
+
+// parseBranchStatement parses a break or continue statement, which must
+// be inside a loop of the same function.
+func (p *Parser) parseBranchStatement() ast.Statement {
+	defer p.untrace(p.trace("parseBranchStatement"))
+
+	var stmt ast.Statement
+	if p.currToken.Is(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.currToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.currToken}
+	}
+
+	if p.loops == 0 {
+		p.errorf(ErrMisplacedBranch, p.currToken, "%s is not in a loop", p.currToken.Literal)
+	}
+
+	if p.nextToken.Is(token.SEMICOLON) {
+		p.getNextToken()
+	}
+
+	return stmt
+}
+
+// parseImportStatement parses an import statement, which must be at the
+// top level of the program.
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	defer p.untrace(p.trace("parseImportStatement"))
+
+	stmt := &ast.ImportStatement{Token: p.currToken}
+
+	if p.braces > 0 {
+		p.errorf(ErrMisplacedImport, p.currToken, "import is only allowed at the top level")
+		return nil
+	}
+
+	if !p.expectNextToken(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+
+	if p.nextToken.Is(token.AS) {
+		p.getNextToken()
+		if !p.expectNextToken(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	} else if name := stmt.Name(); !isIdentifier(name) {
+		p.errorf(ErrInvalidImport, stmt.Path.Token, "cannot bind module %q to %q, import it as a name", stmt.Path.Value, name)
+		return nil
+	}
+
+	if p.nextToken.Is(token.SEMICOLON) {
+		p.getNextToken()
+	}
+
+	return stmt
+}
+
+// isIdentifier reports whether name reads as an identifier.
+func isIdentifier(name string) bool {
+	if name == "" || token.ParseIndent(name).IsNot(token.IDENT) {
+		return false
+	}
+	for i, ch := range name {
+		if !unicode.IsLetter(ch) && ch != '_' && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseExportStatement parses an export statement, a let statement or a
+// function declaration at the top level of the program preceded by export.
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	defer p.untrace(p.trace("parseExportStatement"))
+
+	stmt := &ast.ExportStatement{Token: p.currToken}
+
+	if p.braces > 0 {
+		p.errorf(ErrMisplacedImport, p.currToken, "export is only allowed at the top level")
+		return nil
+	}
+
+	p.getNextToken()
+
+	switch {
+	case p.currToken.Is(token.LET) || p.currToken.Is(token.CONST):
+		if s := p.parseLetStatement(); s != nil {
+			stmt.Statement = s
+		}
+	case p.currToken.Is(token.FUNCTION) && p.nextToken.Is(token.IDENT):
+		if s := p.parseFunctionStatement(); s != nil {
+			stmt.Statement = s
+		}
+	default:
+		p.errorf(ErrUnexpectedToken, p.currToken, "expected let, const or fn declaration after export, got %s instead", p.currToken.Type)
+	}
+
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.untrace(p.trace("parseExpressionStatement"))
+
 	stmt := &ast.ExpressionStatement{Token: p.currToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -69,6 +339,8 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.untrace(p.trace("parseBlockStatement"))
+
 	block := &ast.BlockStatement{Token: p.currToken}
 	block.Statements = []ast.Statement{}
 
@@ -82,5 +354,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.getNextToken()
 	}
 
+	if p.currToken.Is(token.RBRACE) {
+		block.Rbrace = p.currToken
+	} else {
+		p.expectError(p.currToken, token.RBRACE)
+	}
+
 	return block
 }
@@ -2,31 +2,44 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 0
+const traceIndentPlaceholder string = "  "
 
-const traceIdentPlaceholder string = "  "
+// An Option configures a Parser.
+type Option func(*Parser)
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// WithTrace makes the parser write a trace of the parse functions it
+// enters and leaves to w.
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.tracer = w
+	}
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+func (p *Parser) tracePrint(fs string) {
+	indent := strings.Repeat(traceIndentPlaceholder, p.indent-1)
+	fmt.Fprintf(p.tracer, "%-8s%s%s\n", p.currToken.Pos, indent, fs)
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
-
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN" + msg)
+// trace and untrace bracket a parse function when tracing is enabled:
+//
+//	defer p.untrace(p.trace("parseExpression"))
+func (p *Parser) trace(msg string) string {
+	if p.tracer == nil {
+		return msg
+	}
+	p.indent++
+	p.tracePrint(fmt.Sprintf("BEGIN %s %q", msg, p.currToken.Literal))
 	return msg
 }
 
-func untrace(msg string) {
-	tracePrint("END" + msg)
-	decIdent()
+func (p *Parser) untrace(msg string) {
+	if p.tracer == nil {
+		return
+	}
+	p.tracePrint("END " + msg)
+	p.indent--
 }
@@ -8,33 +8,63 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// CONTINUATION_PROMPT asks for the next line of an incomplete statement.
+const CONTINUATION_PROMPT = ".. "
+
+// Start runs the REPL, the statements read from in import modules with
+// the module state modules.
+func Start(in io.Reader, out io.Writer, modules *object.Modules, opts ...parser.Option) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	env := object.NewEnvironment().WithModules(modules)
+	macroEnv := object.NewEnvironment()
+
+	// the lines of the statements read so far, which span several lines
+	// until they parse or a blank line ends them
+	var input string
 
 	for {
-		fmt.Printf(PROMPT)
+		if input == "" {
+			fmt.Printf(PROMPT)
+		} else {
+			fmt.Printf(CONTINUATION_PROMPT)
+		}
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
+		ended := input != "" && strings.TrimSpace(line) == ""
+		input += line + "\n"
 
-		l := lexer.New(line)
-		p := parser.New(l)
+		l := lexer.New(input)
+		p := parser.New(l, opts...)
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
+			if !ended && incomplete(p.ParseErrors()) {
+				continue
+			}
 			printParseErrors(out, p.Errors())
+			input = ""
+			continue
+		}
+		input = ""
+
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			io.WriteString(out, err.Inspect())
+			io.WriteString(out, "\n")
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated := evaluator.Eval(expanded, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -42,6 +72,49 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+// Run evaluates the program read from in, whose errors are positioned in
+// filename, with the module state modules and reports whether it ran
+// without error. The program is tokenized as it is read, it is never
+// loaded in memory as a whole.
+func Run(in io.Reader, out io.Writer, filename string, modules *object.Modules, opts ...parser.Option) bool {
+	l := lexer.NewReader(in).WithFilename(filename)
+	p := parser.New(l, opts...)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParseErrors(out, p.Errors())
+		return false
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		io.WriteString(out, err.Inspect())
+		io.WriteString(out, "\n")
+		return false
+	}
+
+	evaluated := evaluator.Eval(expanded, object.NewEnvironment().WithModules(modules))
+	if evaluated, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
+		return false
+	}
+	return true
+}
+
+// incomplete reports whether errs are all due to the input ending before
+// its statements do, so that more lines may complete it.
+func incomplete(errs parser.ParseError) bool {
+	for _, err := range errs {
+		if !err.Got.IsEOF() && !strings.HasSuffix(err.Msg, "not terminated") {
+			return false
+		}
+	}
+	return true
+}
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
@@ -7,12 +7,21 @@ type TokenType string
 const (
 	ILLEGAL TokenType = "ILLEGAL"
 	EOF               = "EOF"
+	COMMENT           = "COMMENT" // "// line", "/* block */"
 
 	// Identifiers + literals
-	IDENT  = "IDENT"  // add, foobar, x, y, ...
-	INT    = "INT"    // 123456
-	STRING = "STRING" // "hello", 'world'
-	FLOAT  = "FLOAT"  // 0.12, 1.232
+	IDENT      = "IDENT"      // add, foobar, x, y, ...
+	INT        = "INT"        // 123456
+	STRING     = "STRING"     // "hello", 'world'
+	RAW_STRING = "RAW_STRING" // """C:\path"""
+	FLOAT      = "FLOAT"      // 0.12, 1.232
+
+	// Template literals, `a ${x} b ${y} c` is lexed as
+	// TEMPLATE_HEAD x TEMPLATE_MIDDLE y TEMPLATE_TAIL
+	TEMPLATE        = "TEMPLATE"        // `text` without holes
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"   // `text${
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE" // }text${
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"   // }text`
 
 	// Operators
 	ASSIGN   = "="
@@ -21,14 +30,37 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 
-	LT = "<"
-	GT = ">"
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	ARROW = "=>"
+
 	// Delimiters
+	ELLIPSIS  = "..."
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
@@ -47,53 +79,78 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MATCH    = "MATCH"
+	MACRO    = "MACRO"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	CONST    = "CONST"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"match":    MATCH,
+	"macro":    MACRO,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
+	"const":    CONST,
 }
 
 type Token struct {
 	Type    TokenType
 	Literal string
+
+	Pos Position // position of the first character
+	End Position // position immediately after the last character
 }
 
-func New(ttype TokenType, literal string) *Token {
-	return &Token{
+func New(ttype TokenType, literal string) Token {
+	return Token{
 		Type:    ttype,
 		Literal: literal,
 	}
 }
 
-func NewILLEGAL(literal string) *Token {
+func NewILLEGAL(literal string) Token {
 	return New(ILLEGAL, literal)
 }
 
-func ParseIndent(ident string) *Token {
+func ParseIndent(ident string) Token {
 	if ttype, ok := keywords[ident]; ok {
 		return New(ttype, ident)
 	}
 	return New(IDENT, ident)
 }
 
-func (t *Token) Is(ttype TokenType) bool {
+func (t Token) Is(ttype TokenType) bool {
 	return t.Type == ttype
 }
 
-func (t *Token) IsNot(ttype TokenType) bool {
+func (t Token) IsNot(ttype TokenType) bool {
 	return t.Type != ttype
 }
 
-func (t *Token) IsEOF() bool {
+func (t Token) IsEOF() bool {
 	return t.Is(EOF)
 }
 
-func (t *Token) String() string {
+func (t Token) String() string {
 	return fmt.Sprintf("type %s, value %s", t.Type, t.Literal)
 }
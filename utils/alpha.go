@@ -1,17 +1,34 @@
 package utils
 
+import "unicode"
+
 func IsLiteral(ch rune) bool {
 	return IsLetter(ch) || IsDigit(ch)
 }
 
 func IsLetter(ch rune) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+	if ch < 0x80 {
+		return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+	}
+	return unicode.IsLetter(ch)
 }
 
 func IsDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func IsBinaryDigit(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
+func IsOctalDigit(ch rune) bool {
+	return '0' <= ch && ch <= '7'
+}
+
+func IsHexDigit(ch rune) bool {
+	return IsDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
 func IsWhiteSpace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
 }